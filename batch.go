@@ -0,0 +1,114 @@
+package umac
+
+func checkBatch(nonces [][NonceSize]byte, msgs [][]byte, tags []byte, size int) {
+	if len(nonces) != len(msgs) {
+		panic("umac: SumBatch with different numbers of nonces and messages")
+	}
+	if len(tags) < size*len(msgs) {
+		panic("umac: SumBatch tags buffer too short")
+	}
+}
+
+// batchPads xors the pads of nonces into tags, size bytes each, with one pdfCtx,
+// so consecutive nonces sharing an AES block (UMAC-32 and UMAC-64) encrypt it once.
+func batchPads(k *Key, size int, nonces [][NonceSize]byte, tags []byte) {
+	var pdf pdfCtx
+	pdf.init(k)
+	for i, nonce := range nonces {
+		tag := tags[size*i : size*(i+1)]
+		switch size {
+		case 4:
+			pdf.genXor4(nonce, tag)
+		case 8:
+			pdf.genXor8(nonce, tag)
+		case 12:
+			pdf.genXor12(nonce, tag)
+		default:
+			pdf.genXor16(nonce, tag)
+		}
+	}
+}
+
+// SumBatch computes the UMAC-32 tags of msgs under the matching nonces,
+// writing the tag of msgs[i] to tags[4*i:4*i+4].
+// The tags are the same as the ones of MAC. The messages are hashed one by one,
+// only the pads are batched, an AES block is shared by up to 4 consecutive nonces.
+// It panics if nonces and msgs have different lengths or tags is too short.
+func (k *Key4) SumBatch(nonces [][NonceSize]byte, msgs [][]byte, tags []byte) {
+	checkBatch(nonces, msgs, tags, 4)
+	var h uhash4
+	h.init(k.key)
+	for i, msg := range msgs {
+		tag := tags[4*i : 4*i+4]
+		if len(msg) <= L1_KEY_LEN {
+			h.hashShort(msg, tag)
+		} else {
+			h.update(msg)
+			h.final(tag)
+		}
+	}
+	batchPads(k.key, 4, nonces, tags)
+}
+
+// SumBatch computes the UMAC-64 tags of msgs under the matching nonces,
+// writing the tag of msgs[i] to tags[8*i:8*i+8].
+// The tags are the same as the ones of MAC. The messages are hashed one by one,
+// only the pads are batched, an AES block is shared by 2 consecutive nonces.
+// It panics if nonces and msgs have different lengths or tags is too short.
+func (k *Key8) SumBatch(nonces [][NonceSize]byte, msgs [][]byte, tags []byte) {
+	checkBatch(nonces, msgs, tags, 8)
+	var h uhash8
+	h.init(k.key)
+	for i, msg := range msgs {
+		tag := tags[8*i : 8*i+8]
+		if len(msg) <= L1_KEY_LEN {
+			h.hashShort(msg, tag)
+		} else {
+			h.update(msg)
+			h.final(tag)
+		}
+	}
+	batchPads(k.key, 8, nonces, tags)
+}
+
+// SumBatch computes the UMAC-96 tags of msgs under the matching nonces,
+// writing the tag of msgs[i] to tags[12*i:12*i+12].
+// The tags are the same as the ones of MAC. The messages are hashed one by one
+// and every nonce takes an AES block, so it only saves the per-call overhead of MAC.
+// It panics if nonces and msgs have different lengths or tags is too short.
+func (k *Key12) SumBatch(nonces [][NonceSize]byte, msgs [][]byte, tags []byte) {
+	checkBatch(nonces, msgs, tags, 12)
+	var h uhash12
+	h.init(k.key)
+	for i, msg := range msgs {
+		tag := tags[12*i : 12*i+12]
+		if len(msg) <= L1_KEY_LEN {
+			h.hashShort(msg, tag)
+		} else {
+			h.update(msg)
+			h.final(tag)
+		}
+	}
+	batchPads(k.key, 12, nonces, tags)
+}
+
+// SumBatch computes the UMAC-128 tags of msgs under the matching nonces,
+// writing the tag of msgs[i] to tags[16*i:16*i+16].
+// The tags are the same as the ones of MAC. The messages are hashed one by one
+// and every nonce takes an AES block, so it only saves the per-call overhead of MAC.
+// It panics if nonces and msgs have different lengths or tags is too short.
+func (k *Key16) SumBatch(nonces [][NonceSize]byte, msgs [][]byte, tags []byte) {
+	checkBatch(nonces, msgs, tags, 16)
+	var h uhash16
+	h.init(k.key)
+	for i, msg := range msgs {
+		tag := tags[16*i : 16*i+16]
+		if len(msg) <= L1_KEY_LEN {
+			h.hashShort(msg, tag)
+		} else {
+			h.update(msg)
+			h.final(tag)
+		}
+	}
+	batchPads(k.key, 16, nonces, tags)
+}
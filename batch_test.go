@@ -0,0 +1,130 @@
+package umac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+type batcher interface {
+	macer
+	SumBatch(nonces [][NonceSize]byte, msgs [][]byte, tags []byte)
+}
+
+func TestSumBatch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+	data := make([]byte, 3000)
+	rnd.Read(data)
+
+	var (
+		nonces [][NonceSize]byte
+		msgs   [][]byte
+	)
+	for i, n := range []int{0, 1, 31, 32, 33, 40, 64, 200, 1023, 1024, 1025, 2048, 3000} {
+		nonces = append(nonces, [NonceSize]byte{7: byte(i)})
+		msgs = append(msgs, data[:n])
+	}
+	for i := 0; i < 100; i++ {
+		var nonce [NonceSize]byte
+		binary.BigEndian.PutUint64(nonce[:], rnd.Uint64()>>uint(rnd.Intn(64)))
+		nonces = append(nonces, nonce)
+		msgs = append(msgs, data[:40+rnd.Intn(160)])
+	}
+
+	for _, b := range []batcher{k.Key4(), k.Key8(), k.Key12(), k.Key16()} {
+		size := len(b.MAC(nil, make([]byte, NonceSize), nil))
+		tags := bytes.Repeat([]byte{0xaa}, size*len(msgs)+1)
+		b.SumBatch(nonces, msgs, tags)
+		for i, msg := range msgs {
+			if want := b.MAC(nil, nonces[i][:], msg); !bytes.Equal(tags[size*i:size*(i+1)], want) {
+				t.Fatalf("UMAC-%d: message %d (%d bytes): tag %x, expected %x", size*8, i, len(msg), tags[size*i:size*(i+1)], want)
+			}
+		}
+		if tags[len(tags)-1] != 0xaa {
+			t.Errorf("UMAC-%d: SumBatch wrote past the tags", size*8)
+		}
+		b.SumBatch(nil, nil, nil)
+	}
+}
+
+func TestSumBatchPanics(t *testing.T) {
+	k, _ := NewKey8([]byte("abcdefghijklmnop"))
+	for _, f := range []func(){
+		func() { k.SumBatch(make([][NonceSize]byte, 2), make([][]byte, 1), make([]byte, 16)) },
+		func() { k.SumBatch(make([][NonceSize]byte, 2), make([][]byte, 2), make([]byte, 15)) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("SumBatch did not panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+// BenchmarkSumBatch compares 64 messages of 40 to 200 bytes in one SumBatch call,
+// one MAC call each and a Write, Sum, Reset cycle each, for UMAC-64 and UMAC-128.
+func BenchmarkSumBatch(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	const count = 64
+	key := []byte("abcdefghijklmnop")
+	k, _ := NewKey8(key)
+	nonces := make([][NonceSize]byte, count)
+	msgs := make([][]byte, count)
+	total := 0
+	for i := range msgs {
+		binary.BigEndian.PutUint64(nonces[i][:], uint64(i))
+		msgs[i] = make([]byte, 40+rnd.Intn(160))
+		total += len(msgs[i])
+	}
+	tags := make([]byte, 8*count)
+
+	b.Run("batch", func(b *testing.B) {
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			k.SumBatch(nonces, msgs, tags)
+		}
+	})
+	b.Run("mac", func(b *testing.B) {
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			for j, msg := range msgs {
+				k.MAC(tags[8*j:8*j], nonces[j][:], msg)
+			}
+		}
+	})
+	// UMAC-128 takes an AES block per nonce, the batch only saves the per-call overhead
+	k16 := k.key.Key16()
+	tags16 := make([]byte, 16*count)
+	b.Run("batch16", func(b *testing.B) {
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			k16.SumBatch(nonces, msgs, tags16)
+		}
+	})
+	b.Run("mac16", func(b *testing.B) {
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			for j, msg := range msgs {
+				k16.MAC(tags16[16*j:16*j], nonces[j][:], msg)
+			}
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		u := New8(key)
+		var buf [8]byte
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			for j, msg := range msgs {
+				u.Write(msg)
+				copy(buf[:], nonces[j][:])
+				u.Sum(buf[:])
+				u.Reset()
+			}
+		}
+	})
+}
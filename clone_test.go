@@ -0,0 +1,134 @@
+package umac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("bcdefghi")
+	k, _ := NewKey(key)
+	rnd := rand.New(rand.NewSource(1))
+	header := make([]byte, 3000)
+	rnd.Read(header)
+	bodies := [][]byte{nil, make([]byte, 1), make([]byte, 100), make([]byte, 1024), make([]byte, 2000)}
+	for _, b := range bodies {
+		rnd.Read(b)
+	}
+
+	clones := []func(n int) (verifier, func() verifier){
+		func(n int) (verifier, func() verifier) {
+			u := k.New4()
+			u.Write(header[:n])
+			return u, func() verifier { return u.Clone() }
+		},
+		func(n int) (verifier, func() verifier) {
+			u := k.New8()
+			u.Write(header[:n])
+			return u, func() verifier { return u.Clone() }
+		},
+		func(n int) (verifier, func() verifier) {
+			u := k.New12()
+			u.Write(header[:n])
+			return u, func() verifier { return u.Clone() }
+		},
+		func(n int) (verifier, func() verifier) {
+			u := k.New16()
+			u.Write(header[:n])
+			return u, func() verifier { return u.Clone() }
+		},
+	}
+	refs := []macer{k.Key4(), k.Key8(), k.Key12(), k.Key16()}
+	for i, newClone := range clones {
+		for _, split := range []int{0, 1, 31, 64, 1000, 1023, 1024, 1025, 2047, 2048, 2049, 3000} {
+			orig, clone := newClone(split)
+			for _, body := range bodies {
+				c := clone()
+				c.Write(body)
+				tag := c.Sum(bytes.Clone(nonce))
+				if want := refs[i].MAC(nil, nonce, append(header[:split:split], body...)); !bytes.Equal(tag, want) {
+					t.Fatalf("UMAC-%d: header %d, body %d: tag %x, expected %x", c.Size()*8, split, len(body), tag, want)
+				}
+			}
+			// the original is untouched by its clones
+			if tag, want := orig.Sum(bytes.Clone(nonce)), refs[i].MAC(nil, nonce, header[:split]); !bytes.Equal(tag, want) {
+				t.Errorf("UMAC-%d: header %d: original tag %x, expected %x", orig.Size()*8, split, tag, want)
+			}
+		}
+	}
+}
+
+func TestCloneConcurrent(t *testing.T) {
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+	u := k.New16()
+	u.Precompute(make([]byte, 8), 16)
+	u.Write(make([]byte, 1500))
+	h, _ := k.NewHash(8)
+	h.SetNonce(make([]byte, 8))
+	h.Write(make([]byte, 1500))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		c, hc := u.Clone(), h.Clone()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Write([]byte{byte(i)})
+			c.Sum(make([]byte, 8))
+			hc.Write([]byte{byte(i)})
+			hc.Sum(nil)
+		}(i)
+	}
+	wg.Wait()
+
+	want := k.Key8().MAC(nil, make([]byte, 8), make([]byte, 1500))
+	if tag := h.Sum(nil); !bytes.Equal(tag, want) {
+		t.Errorf("Hash tag %x after cloning, expected %x", tag, want)
+	}
+}
+
+// TestClonePads checks that clones share the precomputed pads instead of copying them,
+// and that a hasher writing into the table gets its own copy first.
+func TestClonePads(t *testing.T) {
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+	ref := k.Key8()
+	u := k.New8()
+	var start [NonceSize]byte
+	if err := u.Precompute(start[:], MaxPrecompute); err != nil {
+		t.Fatal(err)
+	}
+	table := &u.pdf.pads[0]
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		c := u.Clone()
+		if &c.pdf.pads[0] != table {
+			t.Fatal("Clone copied the precomputed pads")
+		}
+		wg.Add(1)
+		go func(g int, c *UMAC8) {
+			defer wg.Done()
+			// nonces inside and outside the precomputed range, the latter write into the table
+			for _, n := range []uint64{1, 2, MaxPrecompute + uint64(g), 1 << 40} {
+				nonce := binary.BigEndian.AppendUint64(nil, n)
+				c.Write([]byte("msg"))
+				if tag, want := c.Sum(bytes.Clone(nonce)), ref.MAC(nil, nonce, []byte("msg")); !bytes.Equal(tag, want) {
+					t.Errorf("clone %d, nonce %d: tag %x, expected %x", g, n, tag, want)
+				}
+			}
+		}(g, c)
+	}
+	wg.Wait()
+
+	nonce := binary.BigEndian.AppendUint64(nil, 1<<41)
+	if tag, want := u.Sum(bytes.Clone(nonce)), ref.MAC(nil, nonce, nil); !bytes.Equal(tag, want) {
+		t.Errorf("original: tag %x, expected %x", tag, want)
+	}
+	if &u.pdf.pads[0] == table {
+		t.Error("the original wrote into the table shared with its clones")
+	}
+}
@@ -0,0 +1,66 @@
+package umac
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNonceNotSet is the panic value of Hash.Sum if SetNonce has not been called.
+var ErrNonceNotSet = errors.New("umac: nonce not set")
+
+// ErrInvalidState is returned when unmarshaling a malformed or unsupported hasher state.
+var ErrInvalidState = errors.New("umac: invalid hash state")
+
+// ErrKeyMismatch is returned when unmarshaling a hasher state saved under another key.
+var ErrKeyMismatch = errors.New("umac: hash state of another key")
+
+// ErrNHParams is returned by NewNH for an unsupported number of streams or key length.
+var ErrNHParams = errors.New("umac: invalid NH streams or key length")
+
+// ErrNHTooLong is returned when an NH message is longer than the key.
+var ErrNHTooLong = errors.New("umac: NH message longer than the key")
+
+// ErrNegativeSize is returned by SumParallel when the message size is negative.
+var ErrNegativeSize = errors.New("umac: negative size")
+
+// KeySizeError is returned when the key cannot be used to create the underlying block cipher.
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "umac: invalid key size " + strconv.Itoa(int(k))
+}
+
+// BlockSizeError is returned when the block cipher given to NewKeyWithCipher does not have a 16-byte block.
+type BlockSizeError int
+
+func (b BlockSizeError) Error() string {
+	return "umac: invalid cipher block size " + strconv.Itoa(int(b)) + ", expected 16"
+}
+
+// NonceSizeError is reported when a nonce is not 8 bytes long.
+type NonceSizeError int
+
+func (n NonceSizeError) Error() string {
+	return "umac: invalid nonce size " + strconv.Itoa(int(n)) + ", expected 8"
+}
+
+// TagSizeError is returned when a tag length is not supported.
+type TagSizeError int
+
+func (t TagSizeError) Error() string {
+	return "umac: invalid tag size " + strconv.Itoa(int(t))
+}
+
+// WindowSizeError is returned when a replay window size is out of range.
+type WindowSizeError int
+
+func (w WindowSizeError) Error() string {
+	return "umac: invalid replay window size " + strconv.Itoa(int(w))
+}
+
+// PrecomputeSizeError is returned when the number of pads to precompute is out of range.
+type PrecomputeSizeError int
+
+func (p PrecomputeSizeError) Error() string {
+	return "umac: invalid precompute count " + strconv.Itoa(int(p))
+}
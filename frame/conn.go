@@ -0,0 +1,289 @@
+// Package frame turns a byte stream into a stream of frames authenticated with UMAC.
+//
+// Each frame is sent as
+//
+//	sequence number (8 bytes) || payload length (4 bytes) || payload || tag
+//
+// with big-endian integers. The tag is the UMAC-64 or UMAC-128 of the sequence number,
+// the length and the payload, with the sequence number as the nonce.
+// Every direction has its own key and counts its sequence numbers from zero,
+// so a receiver rejects frames that are forged, reflected, replayed or reordered.
+// The payload is not encrypted.
+package frame
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fakeboboliu/umac"
+)
+
+const (
+	// MaxFrameSize is the largest payload of a frame, Write splits longer buffers.
+	MaxFrameSize = 1 << 20
+
+	headerSize = 12
+)
+
+type mac interface {
+	Write([]byte) (int, error)
+	Sum([]byte) []byte
+	Size() int
+	VerifyTag(nonce, tag []byte) bool
+}
+
+// Conn sends and receives authenticated frames over an underlying stream.
+// One goroutine may read while another writes.
+// It implements net.Conn, the addresses and deadlines are the ones of the underlying stream
+// when it is a net.Conn.
+type Conn struct {
+	rw io.ReadWriter
+
+	rmu     sync.Mutex
+	rmac    mac
+	rseq    uint64
+	rbuf    []byte
+	pending []byte
+	rerr    error
+
+	wmu  sync.Mutex
+	wmac mac
+	wseq uint64
+	wbuf []byte
+	werr error
+}
+
+// New wraps rw, usually a net.Conn, with the given tag size, 8 or 16.
+// Frames are read with readKey and written with writeKey, which the peer uses the other way round.
+func New(rw io.ReadWriter, size int, readKey, writeKey []byte) (*Conn, error) {
+	rk, err := umac.NewKey(readKey)
+	if err != nil {
+		return nil, err
+	}
+	wk, err := umac.NewKey(writeKey)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{rw: rw}
+	switch size {
+	case 8:
+		c.rmac, c.wmac = rk.New8(), wk.New8()
+	case 16:
+		c.rmac, c.wmac = rk.New16(), wk.New16()
+	default:
+		return nil, umac.TagSizeError(size)
+	}
+	return c, nil
+}
+
+// WriteFrame sends p as one frame, p must not be longer than MaxFrameSize.
+// After an error of the underlying stream, all writes fail with it.
+func (c *Conn) WriteFrame(p []byte) error {
+	if len(p) > MaxFrameSize {
+		return FrameSizeError(len(p))
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeFrame(p)
+}
+
+// Write implements io.Writer, sending p in frames of at most MaxFrameSize bytes.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > MaxFrameSize {
+			chunk = chunk[:MaxFrameSize]
+		}
+		if err := c.writeFrame(chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+func (c *Conn) writeFrame(p []byte) error {
+	if c.werr != nil {
+		return c.werr
+	}
+	if c.wseq == 1<<64-1 {
+		c.werr = ErrExhausted
+		return c.werr
+	}
+
+	total := headerSize + len(p) + c.wmac.Size()
+	if cap(c.wbuf) < total {
+		c.wbuf = make([]byte, total)
+	}
+	buf := c.wbuf[:total]
+	binary.BigEndian.PutUint64(buf, c.wseq)
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(p)))
+	copy(buf[headerSize:], p)
+	body := headerSize + len(p)
+	c.wmac.Write(buf[:body])
+	// Sum takes the nonce from its argument and overwrites it with the tag
+	copy(buf[body:], buf[:umac.NonceSize])
+	c.wmac.Sum(buf[body:])
+
+	if n, err := c.rw.Write(buf); err != nil {
+		// a frame that timed out before any of it was sent can be retried
+		if n != 0 || !os.IsTimeout(err) {
+			c.werr = err
+		}
+		return err
+	}
+	c.wseq++
+	return nil
+}
+
+// ReadFrame reads the next frame and appends its payload to dst.
+// It should not be mixed with Read, which may hold part of a frame.
+// A frame failing authentication or out of sequence breaks the stream,
+// the error is then returned by every later read.
+func (c *Conn) ReadFrame(dst []byte) ([]byte, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	p, err := c.readFrame()
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, p...), nil
+}
+
+// Read implements io.Reader, returning the payload of the received frames.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for len(c.pending) == 0 {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		var err error
+		if c.pending, err = c.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *Conn) readFrame() ([]byte, error) {
+	if c.rerr != nil {
+		return nil, c.rerr
+	}
+	p, started, err := c.readFrameErr()
+	// a deadline expiring before any byte of a frame was read leaves the stream intact
+	if err != nil && (started || !os.IsTimeout(err)) {
+		c.rerr = err
+	}
+	return p, err
+}
+
+// readFrameErr reads and checks a frame, started reports whether any byte of it was read.
+func (c *Conn) readFrameErr() (p []byte, started bool, err error) {
+	size := c.rmac.Size()
+	if cap(c.rbuf) < headerSize {
+		c.rbuf = make([]byte, headerSize, headerSize+size)
+	}
+	c.rbuf = c.rbuf[:headerSize]
+	if n, err := io.ReadFull(c.rw, c.rbuf); err != nil {
+		// io.EOF is only returned on a clean end of stream between frames
+		return nil, n != 0, err
+	}
+	length := binary.BigEndian.Uint32(c.rbuf[8:])
+	if length > MaxFrameSize {
+		return nil, true, FrameSizeError(length)
+	}
+	total := headerSize + int(length) + size
+	if cap(c.rbuf) < total {
+		buf := make([]byte, total)
+		copy(buf, c.rbuf)
+		c.rbuf = buf
+	}
+	c.rbuf = c.rbuf[:total]
+	if _, err := io.ReadFull(c.rw, c.rbuf[headerSize:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, true, err
+	}
+
+	body := total - size
+	c.rmac.Write(c.rbuf[:body])
+	if !c.rmac.VerifyTag(c.rbuf[:umac.NonceSize], c.rbuf[body:]) {
+		return nil, true, ErrAuth
+	}
+	seq := binary.BigEndian.Uint64(c.rbuf)
+	switch {
+	case seq < c.rseq:
+		return nil, true, ErrReplay
+	case seq > c.rseq:
+		return nil, true, ErrReorder
+	case seq == 1<<64-1:
+		return nil, true, ErrExhausted
+	}
+	c.rseq++
+	return c.rbuf[headerSize:body], true, nil
+}
+
+// Close closes the underlying stream if it is an io.Closer.
+func (c *Conn) Close() error {
+	if cl, ok := c.rw.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// LocalAddr returns the local address of the underlying stream if it is a net.Conn, or nil.
+func (c *Conn) LocalAddr() net.Addr {
+	if nc, ok := c.rw.(net.Conn); ok {
+		return nc.LocalAddr()
+	}
+	return nil
+}
+
+// RemoteAddr returns the remote address of the underlying stream if it is a net.Conn, or nil.
+func (c *Conn) RemoteAddr() net.Addr {
+	if nc, ok := c.rw.(net.Conn); ok {
+		return nc.RemoteAddr()
+	}
+	return nil
+}
+
+// SetDeadline sets the read and write deadlines of the underlying stream,
+// it fails with os.ErrNoDeadline if the stream is not a net.Conn.
+// A deadline expiring in the middle of a frame breaks the stream like any other error,
+// one expiring between frames can be retried.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if nc, ok := c.rw.(net.Conn); ok {
+		return nc.SetDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
+
+// SetReadDeadline sets the read deadline of the underlying stream, see SetDeadline.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	if nc, ok := c.rw.(net.Conn); ok {
+		return nc.SetReadDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
+
+// SetWriteDeadline sets the write deadline of the underlying stream, see SetDeadline.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	if nc, ok := c.rw.(net.Conn); ok {
+		return nc.SetWriteDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
+
+var _ net.Conn = (*Conn)(nil)
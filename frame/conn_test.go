@@ -0,0 +1,225 @@
+package frame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fakeboboliu/umac"
+)
+
+var (
+	keyA = []byte("abcdefghijklmnop")
+	keyB = []byte("ponmlkjihgfedcba")
+)
+
+func pipe(t *testing.T, size int) (*Conn, *Conn) {
+	a, b := net.Pipe()
+	ca, err := New(a, size, keyB, keyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := New(b, size, keyA, keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ca.Close()
+		cb.Close()
+	})
+	return ca, cb
+}
+
+func TestFrames(t *testing.T) {
+	for _, size := range []int{8, 16} {
+		a, b := pipe(t, size)
+		msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 5000), []byte("bye")}
+		go func() {
+			for _, m := range msgs {
+				if err := a.WriteFrame(m); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		for i, m := range msgs {
+			got, err := b.ReadFrame(nil)
+			if err != nil {
+				t.Fatalf("size %d: frame %d: %v", size, i, err)
+			}
+			if !bytes.Equal(got, m) {
+				t.Errorf("size %d: frame %d = %q, expected %q", size, i, got, m)
+			}
+		}
+
+		// the other direction, through the io.Reader and io.Writer interfaces
+		data := bytes.Repeat([]byte("0123456789"), MaxFrameSize/4)
+		go func() {
+			if _, err := b.Write(data); err != nil {
+				t.Error(err)
+			}
+			b.Close()
+		}()
+		got, err := io.ReadAll(a)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: read %d bytes, expected %d", size, len(got), len(data))
+		}
+	}
+}
+
+// record captures the frames written by a Conn.
+type record struct {
+	frames [][]byte
+}
+
+func (r *record) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (r *record) Write(p []byte) (int, error) {
+	r.frames = append(r.frames, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestRejects(t *testing.T) {
+	var rec record
+	w, _ := New(&rec, 8, keyB, keyA)
+	for _, m := range []string{"first", "second", "third"} {
+		w.WriteFrame([]byte(m))
+	}
+	f := rec.frames
+	tampered := bytes.Clone(f[0])
+	tampered[headerSize] ^= 1
+	oversized := bytes.Clone(f[0])
+	binary.BigEndian.PutUint32(oversized[8:], MaxFrameSize+1)
+
+	// a frame sent by the receiver itself, under the other key
+	var own record
+	r0, _ := New(&own, 8, keyA, keyB)
+	r0.WriteFrame([]byte("first"))
+
+	for _, tc := range []struct {
+		name   string
+		frames [][]byte
+		err    error
+	}{
+		{"replay", [][]byte{f[0], f[1], f[0]}, ErrReplay},
+		{"duplicate", [][]byte{f[0], f[0]}, ErrReplay},
+		{"reorder", [][]byte{f[1], f[0]}, ErrReorder},
+		{"drop", [][]byte{f[0], f[2]}, ErrReorder},
+		{"tampered", [][]byte{tampered}, ErrAuth},
+		{"reflected", [][]byte{own.frames[0]}, ErrAuth},
+		{"truncated", [][]byte{f[0][:len(f[0])-1]}, io.ErrUnexpectedEOF},
+		{"oversized", [][]byte{oversized}, FrameSizeError(MaxFrameSize + 1)},
+	} {
+		r, _ := New(bytes.NewBuffer(bytes.Join(tc.frames, nil)), 8, keyA, keyB)
+		var err error
+		for range tc.frames {
+			if _, err = r.ReadFrame(nil); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v, expected %v", tc.name, err, tc.err)
+		}
+		if _, err2 := r.ReadFrame(nil); err2 != err {
+			t.Errorf("%s: error not sticky: %v", tc.name, err2)
+		}
+	}
+
+	r, _ := New(new(bytes.Buffer), 8, keyA, keyB)
+	if _, err := r.ReadFrame(nil); err != io.EOF {
+		t.Errorf("empty stream: err = %v, expected io.EOF", err)
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	var rec record
+	w, _ := New(&rec, 16, keyB, keyA)
+	w.wseq = 1<<64 - 2
+	if err := w.WriteFrame(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFrame(nil); err != ErrExhausted {
+		t.Errorf("err = %v, expected ErrExhausted", err)
+	}
+
+	r, _ := New(bytes.NewBuffer(rec.frames[0]), 16, keyA, keyB)
+	r.rseq = 1<<64 - 2
+	if _, err := r.ReadFrame(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(nil, 12, keyA, keyB); err != umac.TagSizeError(12) {
+		t.Errorf("tag size 12: err = %v", err)
+	}
+	if _, err := New(nil, 8, keyA, keyB[:5]); !errors.As(err, new(umac.KeySizeError)) {
+		t.Errorf("5-byte key: err = %v", err)
+	}
+}
+
+func TestNetConn(t *testing.T) {
+	a, b := pipe(t, 8)
+	if a.LocalAddr() == nil || a.RemoteAddr() == nil {
+		t.Error("addresses of the net.Conn not forwarded")
+	}
+
+	// a deadline expiring between frames leaves the stream usable
+	if err := b.SetReadDeadline(time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ReadFrame(nil); !os.IsTimeout(err) {
+		t.Fatalf("err = %v, expected a timeout", err)
+	}
+	b.SetReadDeadline(time.Time{})
+	go a.WriteFrame([]byte("late"))
+	if got, err := b.ReadFrame(nil); err != nil || string(got) != "late" {
+		t.Errorf("after a timeout: %q, %v", got, err)
+	}
+
+	var buf bytes.Buffer
+	c, _ := New(&buf, 8, keyA, keyB)
+	if c.LocalAddr() != nil || c.RemoteAddr() != nil {
+		t.Error("addresses without a net.Conn")
+	}
+	if err := c.SetDeadline(time.Now()); err != os.ErrNoDeadline {
+		t.Errorf("SetDeadline without a net.Conn: err = %v", err)
+	}
+}
+
+func TestWriteFrameNoAlloc(t *testing.T) {
+	c, _ := New(struct {
+		io.Reader
+		io.Writer
+	}{nil, io.Discard}, 16, keyA, keyB)
+	p := make([]byte, 1000)
+	c.WriteFrame(p)
+	if n := testing.AllocsPerRun(100, func() { c.WriteFrame(p) }); n != 0 {
+		t.Errorf("WriteFrame allocated %v times", n)
+	}
+}
+
+func BenchmarkFrame(b *testing.B) {
+	var rec record
+	w, _ := New(&rec, 8, keyB, keyA)
+	w.WriteFrame(make([]byte, 1024))
+	frame := rec.frames[0]
+	rec.frames = nil
+	var buf bytes.Buffer
+	r, _ := New(&buf, 8, keyA, keyB)
+	b.SetBytes(1024)
+	for i := 0; i < b.N; i++ {
+		buf.Write(frame)
+		r.rseq = 0
+		if _, err := r.ReadFrame(nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package frame
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrAuth is returned when the tag of a received frame does not match.
+	ErrAuth = errors.New("frame: message authentication failed")
+	// ErrReplay is returned when a received frame carries an already used sequence number.
+	ErrReplay = errors.New("frame: replayed frame")
+	// ErrReorder is returned when a received frame skips ahead of the expected sequence number.
+	ErrReorder = errors.New("frame: frame out of order")
+	// ErrExhausted is returned when all 2^64 sequence numbers of a direction have been used.
+	ErrExhausted = errors.New("frame: sequence numbers exhausted")
+)
+
+// FrameSizeError is returned when a received frame is larger than MaxFrameSize.
+type FrameSizeError int
+
+func (e FrameSizeError) Error() string {
+	return "frame: invalid frame size " + strconv.Itoa(int(e))
+}
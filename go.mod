@@ -1,3 +1,5 @@
 module github.com/fakeboboliu/umac
 
 go 1.20
+
+require golang.org/x/sys v0.9.0
@@ -0,0 +1,99 @@
+package umac
+
+import "hash"
+
+type umac interface {
+	hash.Hash
+	Precompute(nonce []byte, count int) error
+	tag(nonce [8]byte, out []byte)
+	clone() umac
+}
+
+// Hash is a UMAC that behaves like a standard hash.Hash.
+//
+// The nonce is set with SetNonce instead of being passed to Sum, and Sum
+// appends the tag to its argument without changing the underlying state,
+// so it can be called several times and with a nil slice.
+// Reset clears the nonce along with the message, so a new one has to be set
+// for every message, Sum panics otherwise instead of reusing the last one.
+type Hash struct {
+	mac      umac
+	nonce    [NonceSize]byte
+	nonceSet bool
+	out      [16]byte
+}
+
+// NewHash creates a Hash with the given AES key and tag size,
+// which should be one of 4, 8, 12 and 16.
+func NewHash(key []byte, size int) (*Hash, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.NewHash(size)
+}
+
+// NewHash creates a Hash using the key schedule, size should be one of 4, 8, 12 and 16.
+func (k *Key) NewHash(size int) (*Hash, error) {
+	var mac umac
+	switch size {
+	case 4:
+		mac = k.New4()
+	case 8:
+		mac = k.New8()
+	case 12:
+		mac = k.New12()
+	case 16:
+		mac = k.New16()
+	default:
+		return nil, TagSizeError(size)
+	}
+	return &Hash{mac: mac}, nil
+}
+
+// SetNonce sets the nonce used by following Sum calls, it should be 8 bytes long.
+func (h *Hash) SetNonce(nonce []byte) error {
+	if len(nonce) != NonceSize {
+		return NonceSizeError(len(nonce))
+	}
+	copy(h.nonce[:], nonce)
+	h.nonceSet = true
+	return nil
+}
+
+func (h *Hash) Write(p []byte) (n int, err error) {
+	return h.mac.Write(p)
+}
+
+// Sum appends the tag of the data written so far to b.
+// It panics with ErrNonceNotSet if SetNonce has not been called since the last Reset.
+func (h *Hash) Sum(b []byte) []byte {
+	if !h.nonceSet {
+		panic(ErrNonceNotSet)
+	}
+	out := h.out[:h.mac.Size()]
+	h.mac.tag(h.nonce, out)
+	return append(b, out...)
+}
+
+// Clone returns an independent copy of h, including its nonce.
+func (h *Hash) Clone() *Hash {
+	c := *h
+	c.mac = h.mac.clone()
+	return &c
+}
+
+// Reset clears the data written so far and the nonce, SetNonce must be called again before Sum.
+func (h *Hash) Reset() {
+	h.mac.Reset()
+	h.nonce = [NonceSize]byte{}
+	h.nonceSet = false
+}
+
+func (h *Hash) Size() int {
+	return h.mac.Size()
+}
+
+func (h *Hash) BlockSize() int {
+	return h.mac.BlockSize()
+}
@@ -0,0 +1,105 @@
+package umac
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHashRFC4418(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	for _, size := range []int{4, 8, 12} {
+		h, err := NewHash(key, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range rfcVectors {
+			h.Reset()
+			if err := h.SetNonce([]byte("bcdefghi")); err != nil {
+				t.Fatal(err)
+			}
+			h.Write(bytes.Repeat([]byte(v.pattern), v.count))
+			want := map[int]string{4: v.tag4, 8: v.tag8, 12: v.tag12}[size]
+			target, _ := hex.DecodeString(want)
+			for i := 0; i < 2; i++ {
+				if tag := h.Sum(nil); !bytes.Equal(tag, target) {
+					t.Errorf("UMAC-%d of %q*%d, Sum #%d: %X, expected %s", size*8, v.pattern, v.count, i, tag, want)
+				}
+			}
+		}
+	}
+}
+
+func TestHashSumKeepsState(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("abcdefgh")
+	data := bytes.Repeat([]byte{'a'}, 3000)
+
+	h, _ := NewHash(key, 16)
+	h.SetNonce(nonce)
+	ref := New16(key)
+	for _, n := range []int{0, 3, 1021, 1024, 2000} {
+		h.Write(data[:n])
+		ref.Write(data[:n])
+		prefix := []byte("prefix")
+		got := h.Sum(prefix)
+
+		// the legacy Sum overwrites its argument, so give it a copy of the nonce
+		want := ref.Sum(append([]byte(nil), nonce...))
+		if !bytes.Equal(got[:len(prefix)], prefix) || !bytes.Equal(got[len(prefix):], want) {
+			t.Fatalf("Sum after %d bytes: %x, expected %x", n, got, want)
+		}
+		ref.Reset()
+		h.Reset()
+		h.SetNonce(nonce)
+	}
+
+	h.Write(data[:1500])
+	first := h.Sum(nil)
+	h.Write(data[1500:])
+	ref.Write(data)
+	if want := ref.Sum(append([]byte(nil), nonce...)); !bytes.Equal(h.Sum(nil), want) {
+		t.Errorf("writing after Sum gave a different tag")
+	}
+	if bytes.Equal(first, h.Sum(nil)) {
+		t.Errorf("tag did not change after more data was written")
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	if _, err := NewHash(make([]byte, 16), 10); err != TagSizeError(10) {
+		t.Errorf("NewHash with invalid size: %v", err)
+	}
+	if _, err := NewHash(make([]byte, 3), 8); err != KeySizeError(3) {
+		t.Errorf("NewHash with invalid key: %v", err)
+	}
+	h, _ := NewHash(make([]byte, 16), 8)
+	if err := h.SetNonce(make([]byte, 7)); err != NonceSizeError(7) {
+		t.Errorf("SetNonce with short nonce: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r != ErrNonceNotSet {
+			t.Errorf("Sum without nonce panicked with %v", r)
+		}
+	}()
+	h.Sum(nil)
+}
+
+// TestHashResetClearsNonce checks that the usual Reset, Write, Sum cycle
+// cannot silently reuse the nonce of the previous message.
+func TestHashResetClearsNonce(t *testing.T) {
+	h, _ := NewHash(make([]byte, 16), 8)
+	h.SetNonce([]byte("bcdefghi"))
+	h.Write([]byte("first"))
+	h.Sum(nil)
+	h.Reset()
+	h.Write([]byte("second"))
+	defer func() {
+		if r := recover(); r != ErrNonceNotSet {
+			t.Errorf("Sum after Reset panicked with %v, expected ErrNonceNotSet", r)
+		}
+	}()
+	h.Sum(nil)
+	t.Error("Sum after Reset reused the previous nonce")
+}
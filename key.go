@@ -0,0 +1,335 @@
+package umac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"sync"
+)
+
+// Key is an expanded UMAC key, holding the NH, polynomial and inner-product
+// keys and the pad cipher of all tag lengths.
+//
+// A Key is read only once created and safe for concurrent use, the hashers
+// created from it share the key material and carry only their running state,
+// so they are cheap to create, e.g. one per goroutine.
+type Key struct {
+	nh      [nhKeyLen / 4]uint32  // nh key
+	polyKey [STREAMS16]uint64     // poly_key_8
+	ipKeys  [STREAMS16 * 4]uint64 // ip_keys
+	ipTrans [STREAMS16]uint32     // ip_trans
+
+	kdf     cipher.Block        // block cipher of the key, derives NH keys of other lengths
+	pad     cipher.Block        // block cipher for pdf
+	padZero [aes.BlockSize]byte // pad of the zero nonce, seeds pdf caches
+
+	fingerprint [8]byte // identifies the key in marshaled hasher states
+}
+
+// NewKey expands the given AES key.
+func NewKey(key []byte) (*Key, error) {
+	k, err := NewKeyWithCipher(key, aes.NewCipher)
+	if err != nil {
+		return nil, KeySizeError(len(key))
+	}
+	return k, nil
+}
+
+// NewKeyWithCipher expands key using the block cipher created by newCipher instead of AES,
+// as RFC 4418 allows. The cipher must have a 16-byte block.
+//
+// newCipher is called with key for the key derivation, then with a derived 16-byte key
+// for the pad, its errors are returned unchanged.
+// With aes.NewCipher it is the same as NewKey, another cipher, like SM4, gives tags
+// that only interoperate with implementations using that cipher.
+func NewKeyWithCipher(key []byte, newCipher func([]byte) (cipher.Block, error)) (*Key, error) {
+	cip, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if bs := cip.BlockSize(); bs != aes.BlockSize {
+		return nil, BlockSizeError(bs)
+	}
+	k := &Key{}
+	if err := k.init(cip, newCipher); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Key) init(cip cipher.Block, newCipher func([]byte) (cipher.Block, error)) error {
+	k.kdf = cip
+	var nhKey [nhKeyLen]byte
+	kdf(cip, 1, nhKey[:])
+	for i := range k.nh {
+		k.nh[i] = binary.BigEndian.Uint32(nhKey[4*i:])
+	}
+
+	buf := [(8*STREAMS16 + 4) * 8]byte{}
+	kdf(cip, 2, buf[:])
+	for i := 0; i < STREAMS16; i++ {
+		k.polyKey[i] = binary.BigEndian.Uint64(buf[24*i:])
+		k.polyKey[i] &= 0x01ffffff<<32 + 0x01ffffff
+	}
+	kdf(cip, 3, buf[:])
+	for i := 0; i < STREAMS16; i++ {
+		from := buf[(8*i+4)*8:]
+		k.ipKeys[4*i] = binary.BigEndian.Uint64(from) % p36
+		k.ipKeys[4*i+1] = binary.BigEndian.Uint64(from[8:]) % p36
+		k.ipKeys[4*i+2] = binary.BigEndian.Uint64(from[16:]) % p36
+		k.ipKeys[4*i+3] = binary.BigEndian.Uint64(from[24:]) % p36
+	}
+	kdf(cip, 4, buf[:STREAMS16*4])
+	for i := 0; i < STREAMS16; i++ {
+		k.ipTrans[i] = binary.BigEndian.Uint32(buf[4*i:])
+	}
+
+	// index 255 is not used by UMAC, its output reveals nothing about the other keys
+	kdf(cip, 255, k.fingerprint[:])
+
+	var padKey [aes.BlockSize]byte
+	kdf(cip, 0, padKey[:])
+	pad, err := newCipher(padKey[:])
+	if err != nil {
+		return err
+	}
+	if bs := pad.BlockSize(); bs != aes.BlockSize {
+		return BlockSizeError(bs)
+	}
+	k.pad = pad
+	k.pad.Encrypt(k.padZero[:], k.padZero[:])
+	return nil
+}
+
+// padBlock writes the pad block of nonce to block, which must be aes.BlockSize long,
+// mask selects the low bits of the nonce used as index into the block.
+func (k *Key) padBlock(nonce [8]byte, mask byte, block []byte) {
+	copy(block, nonce[:])
+	block[7] &= ^mask
+	for i := 8; i < aes.BlockSize; i++ {
+		block[i] = 0
+	}
+	k.pad.Encrypt(block, block)
+}
+
+// xorPad xors the pad of nonce into out, mask selects the low bits of the nonce
+// used as index into the pad block, in units of len(out).
+func (k *Key) xorPad(nonce [8]byte, mask byte, out []byte) {
+	block := padPool.Get().(*[aes.BlockSize]byte)
+	k.padBlock(nonce, mask, block[:])
+	ndx := int(nonce[7]&mask) * len(out)
+	subtle.XORBytes(out, out, block[ndx:])
+	// do not leave the rest of the pad behind
+	*block = [aes.BlockSize]byte{}
+	padPool.Put(block)
+}
+
+// padPool holds the blocks xorPad encrypts the pad in,
+// they are passed to the cipher so they always escape.
+var padPool = sync.Pool{New: func() any { return new([aes.BlockSize]byte) }}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the extension,
+// a new slice is only allocated if in has not enough capacity.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
+
+// New4 creates a UMAC-32 hasher sharing the key schedule.
+func (k *Key) New4() *UMAC4 {
+	u := &UMAC4{}
+	u.pdf.init(k)
+	u.hash.init(k)
+	return u
+}
+
+// New8 creates a UMAC-64 hasher sharing the key schedule.
+func (k *Key) New8() *UMAC8 {
+	u := &UMAC8{}
+	u.pdf.init(k)
+	u.hash.init(k)
+	return u
+}
+
+// New12 creates a UMAC-96 hasher sharing the key schedule.
+func (k *Key) New12() *UMAC12 {
+	u := &UMAC12{}
+	u.pdf.init(k)
+	u.hash.init(k)
+	return u
+}
+
+// New16 creates a UMAC-128 hasher sharing the key schedule.
+func (k *Key) New16() *UMAC16 {
+	u := &UMAC16{}
+	u.pdf.init(k)
+	u.hash.init(k)
+	return u
+}
+
+// Key4 computes UMAC-32 tags in one shot.
+// A Key4 is read only and safe for concurrent use.
+type Key4 struct {
+	key *Key
+}
+
+// NewKey4 prepares a UMAC-32 key from the given AES key.
+func NewKey4(key []byte) (*Key4, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.Key4(), nil
+}
+
+// Key4 returns a one-shot UMAC-32 key sharing the key schedule.
+func (k *Key) Key4() *Key4 {
+	return &Key4{key: k}
+}
+
+// MAC appends the tag of msg under the 8-byte nonce to dst and returns the result.
+// Only the tag is written to dst, it does not allocate if dst has room for it.
+func (k *Key4) MAC(dst, nonce, msg []byte) []byte {
+	n := nonceOf(nonce)
+	var (
+		h   uhash4
+		out [4]byte
+	)
+	h.init(k.key)
+	if len(msg) <= L1_KEY_LEN {
+		h.hashShort(msg, out[:])
+	} else {
+		h.update(msg)
+		h.final(out[:])
+	}
+
+	k.key.xorPad(n, 0x03, out[:])
+	return append(dst, out[:]...)
+}
+
+// Key8 computes UMAC-64 tags in one shot.
+// A Key8 is read only and safe for concurrent use.
+type Key8 struct {
+	key *Key
+}
+
+// NewKey8 prepares a UMAC-64 key from the given AES key.
+func NewKey8(key []byte) (*Key8, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.Key8(), nil
+}
+
+// Key8 returns a one-shot UMAC-64 key sharing the key schedule.
+func (k *Key) Key8() *Key8 {
+	return &Key8{key: k}
+}
+
+// MAC appends the tag of msg under the 8-byte nonce to dst and returns the result.
+// Only the tag is written to dst, it does not allocate if dst has room for it.
+func (k *Key8) MAC(dst, nonce, msg []byte) []byte {
+	n := nonceOf(nonce)
+	var (
+		h   uhash8
+		out [8]byte
+	)
+	h.init(k.key)
+	if len(msg) <= L1_KEY_LEN {
+		h.hashShort(msg, out[:])
+	} else {
+		h.update(msg)
+		h.final(out[:])
+	}
+
+	k.key.xorPad(n, 0x01, out[:])
+	return append(dst, out[:]...)
+}
+
+// Key12 computes UMAC-96 tags in one shot.
+// A Key12 is read only and safe for concurrent use.
+type Key12 struct {
+	key *Key
+}
+
+// NewKey12 prepares a UMAC-96 key from the given AES key.
+func NewKey12(key []byte) (*Key12, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.Key12(), nil
+}
+
+// Key12 returns a one-shot UMAC-96 key sharing the key schedule.
+func (k *Key) Key12() *Key12 {
+	return &Key12{key: k}
+}
+
+// MAC appends the tag of msg under the 8-byte nonce to dst and returns the result.
+// Only the tag is written to dst, it does not allocate if dst has room for it.
+func (k *Key12) MAC(dst, nonce, msg []byte) []byte {
+	n := nonceOf(nonce)
+	var (
+		h   uhash12
+		out [12]byte
+	)
+	h.init(k.key)
+	if len(msg) <= L1_KEY_LEN {
+		h.hashShort(msg, out[:])
+	} else {
+		h.update(msg)
+		h.final(out[:])
+	}
+
+	k.key.xorPad(n, 0, out[:])
+	return append(dst, out[:]...)
+}
+
+// Key16 computes UMAC-128 tags in one shot.
+// A Key16 is read only and safe for concurrent use.
+type Key16 struct {
+	key *Key
+}
+
+// NewKey16 prepares a UMAC-128 key from the given AES key.
+func NewKey16(key []byte) (*Key16, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.Key16(), nil
+}
+
+// Key16 returns a one-shot UMAC-128 key sharing the key schedule.
+func (k *Key) Key16() *Key16 {
+	return &Key16{key: k}
+}
+
+// MAC appends the tag of msg under the 8-byte nonce to dst and returns the result.
+// Only the tag is written to dst, it does not allocate if dst has room for it.
+func (k *Key16) MAC(dst, nonce, msg []byte) []byte {
+	n := nonceOf(nonce)
+	var (
+		h   uhash16
+		out [16]byte
+	)
+	h.init(k.key)
+	if len(msg) <= L1_KEY_LEN {
+		h.hashShort(msg, out[:])
+	} else {
+		h.update(msg)
+		h.final(out[:])
+	}
+
+	k.key.xorPad(n, 0, out[:])
+	return append(dst, out[:]...)
+}
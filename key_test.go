@@ -0,0 +1,256 @@
+package umac
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"hash"
+	"sync"
+	"testing"
+)
+
+type macer interface {
+	MAC(dst, nonce, msg []byte) []byte
+}
+
+func TestKeyMAC(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("bcdefghi")
+	data := make([]byte, 3000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	k4, _ := NewKey4(key)
+	k8, _ := NewKey8(key)
+	k12, _ := NewKey12(key)
+	k16, _ := NewKey16(key)
+	keys := []macer{k4, k8, k12, k16}
+	refs := []func() hash.Hash{
+		func() hash.Hash { return New4(key) },
+		func() hash.Hash { return New8(key) },
+		func() hash.Hash { return New12(key) },
+		func() hash.Hash { return New16(key) },
+	}
+	for i, k := range keys {
+		ref := refs[i]()
+		for _, n := range []int{0, 1, 31, 32, 33, 63, 64, 65, 1000, 1023, 1024, 1025, 2048, 3000} {
+			ref.Write(data[:n])
+			want := ref.Sum(append([]byte(nil), nonce...))
+			ref.Reset()
+			got := k.MAC([]byte("dst"), nonce, data[:n])
+			if !bytes.Equal(got[:3], []byte("dst")) || !bytes.Equal(got[3:], want) {
+				t.Errorf("UMAC-%d MAC of %d bytes: %x, expected %x", ref.Size()*8, n, got[3:], want)
+			}
+		}
+	}
+}
+
+func TestKeyMACNoAlloc(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	k4, _ := NewKey4(key)
+	k8, _ := NewKey8(key)
+	k12, _ := NewKey12(key)
+	k16, _ := NewKey16(key)
+	nonce := []byte("bcdefghi")
+	msg := make([]byte, 200)
+	dst := make([]byte, 0, 16)
+	// Key4 and Key8 take the masked pad path of xorPad, Key12 the truncated one
+	for _, k := range []macer{k4, k8, k12, k16} {
+		if n := testing.AllocsPerRun(100, func() {
+			k.MAC(dst, nonce, msg)
+		}); n != 0 {
+			t.Errorf("MAC allocated %v times", n)
+		}
+	}
+}
+
+// TestKeyMACSpareCapacity checks that MAC writes only the tag, so tags can be filled
+// into adjacent slots of one buffer in any order.
+func TestKeyMACSpareCapacity(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	k4, _ := NewKey4(key)
+	k8, _ := NewKey8(key)
+	k12, _ := NewKey12(key)
+	k16, _ := NewKey16(key)
+	nonce := []byte("bcdefghi")
+	for i, k := range []macer{k4, k8, k12, k16} {
+		size := 4 * (i + 1)
+		buf := bytes.Repeat([]byte{0xaa}, 3*size)
+		tag := k.MAC(buf[size:size], nonce, []byte("msg"))
+		if len(tag) != size || &tag[0] != &buf[size] {
+			t.Fatalf("UMAC-%d: tag not written in place", size*8)
+		}
+		for j, b := range buf {
+			if (j < size || j >= 2*size) && b != 0xaa {
+				t.Fatalf("UMAC-%d: byte %d outside the tag overwritten", size*8, j)
+			}
+		}
+	}
+}
+
+func TestKeyConcurrent(t *testing.T) {
+	k, err := NewKey([]byte("abcdefghijklmnop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("abc"), 1000)
+	nonce := []byte("bcdefghi")
+
+	var want [][]byte
+	for n := 0; n < len(data); n += 100 {
+		u := New16([]byte("abcdefghijklmnop"))
+		u.Write(data[:n])
+		want = append(want, u.Sum(append([]byte(nil), nonce...)))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			u := k.New16()
+			oneShot := k.Key16()
+			for i := range want {
+				n := i * 100
+				// different split points per goroutine
+				u.Write(data[:n/(g+1)])
+				u.Write(data[n/(g+1) : n])
+				got := u.Sum(append([]byte(nil), nonce...))
+				if !bytes.Equal(got, want[i]) {
+					errs <- "hasher"
+					return
+				}
+				if !bytes.Equal(oneShot.MAC(nil, nonce, data[:n]), want[i]) {
+					errs <- "one-shot"
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Errorf("%s tag mismatch with shared key", e)
+	}
+}
+
+func BenchmarkNewUMAC8(b *testing.B) {
+	key := make([]byte, 16)
+	for i := 0; i < b.N; i++ {
+		NewUMAC8(key)
+	}
+}
+
+func BenchmarkKeyNew8(b *testing.B) {
+	k, _ := NewKey(make([]byte, 16))
+	for i := 0; i < b.N; i++ {
+		k.New8()
+	}
+}
+
+// fakeBlock is a deterministic stand-in for a block cipher, it is not a permutation
+// but the key derivation and the pad only need a function of key and input.
+type fakeBlock struct {
+	key  []byte
+	size int
+}
+
+func (f *fakeBlock) BlockSize() int { return f.size }
+
+func (f *fakeBlock) Encrypt(dst, src []byte) {
+	var out [16]byte
+	for i := 0; i < f.size; i++ {
+		out[i] = (src[(i+1)%f.size] ^ f.key[i%len(f.key)]) + byte(i*31)
+	}
+	copy(dst, out[:f.size])
+}
+
+func (f *fakeBlock) Decrypt(dst, src []byte) { panic("not used") }
+
+func TestNewKeyWithCipher(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("bcdefghi")
+	msg := bytes.Repeat([]byte("abc"), 500)
+
+	k, err := NewKeyWithCipher(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := New16(key)
+	want.Write(msg)
+	if got, w := k.Key16().MAC(nil, nonce, msg), want.Sum(append([]byte(nil), nonce...)); !bytes.Equal(got, w) {
+		t.Fatalf("AES: got %X, want %X", got, w)
+	}
+
+	var keys [][]byte
+	newFake := func(key []byte) (cipher.Block, error) {
+		keys = append(keys, append([]byte(nil), key...))
+		return &fakeBlock{key: append([]byte(nil), key...), size: 16}, nil
+	}
+	fk, err := NewKeyWithCipher(key, newFake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || !bytes.Equal(keys[0], key) {
+		t.Fatalf("cipher created with keys %X", keys)
+	}
+	// the pad key is derived with the fake cipher too
+	var padKey [16]byte
+	kdf(&fakeBlock{key: key, size: 16}, 0, padKey[:])
+	if !bytes.Equal(keys[1], padKey[:]) {
+		t.Fatalf("pad cipher key %X, want %X", keys[1], padKey)
+	}
+
+	// the tag is UHASH, with the NH, poly and inner-product keys of the fake cipher,
+	// xored with the pad of the fake cipher
+	u, _ := fk.NewUHASH(16)
+	u.Write(msg)
+	w := u.Sum(nil)
+	var block [16]byte
+	copy(block[:], nonce)
+	(&fakeBlock{key: padKey[:], size: 16}).Encrypt(block[:], block[:])
+	for i := range w {
+		w[i] ^= block[i]
+	}
+	got := fk.Key16().MAC(nil, nonce, msg)
+	if !bytes.Equal(got, w) {
+		t.Fatalf("fake: got %X, want %X", got, w)
+	}
+	h := fk.New16()
+	h.Write(msg)
+	if s := h.Sum(append([]byte(nil), nonce...)); !bytes.Equal(s, w) {
+		t.Fatalf("fake hasher: got %X, want %X", s, w)
+	}
+	if aesTag := k.Key16().MAC(nil, nonce, msg); bytes.Equal(aesTag, w) {
+		t.Fatal("fake cipher gives the AES tag")
+	}
+}
+
+func TestNewKeyWithCipherErrors(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	errCipher := errors.New("no cipher")
+	_, err := NewKeyWithCipher(key, func([]byte) (cipher.Block, error) { return nil, errCipher })
+	if err != errCipher {
+		t.Errorf("got %v, want the error of the cipher", err)
+	}
+	_, err = NewKeyWithCipher(key, func(k []byte) (cipher.Block, error) { return &fakeBlock{key: k, size: 8}, nil })
+	if err != BlockSizeError(8) {
+		t.Errorf("got %v, want BlockSizeError(8)", err)
+	}
+	// the pad cipher is created with a 16-byte key, which the factory may reject
+	_, err = NewKeyWithCipher(make([]byte, 32), func(k []byte) (cipher.Block, error) {
+		if len(k) != 32 {
+			return nil, errCipher
+		}
+		return &fakeBlock{key: k, size: 16}, nil
+	})
+	if err != errCipher {
+		t.Errorf("got %v, want the error of the pad cipher", err)
+	}
+	if _, err := NewKey(key[:5]); err != KeySizeError(5) {
+		t.Errorf("got %v, want KeySizeError(5)", err)
+	}
+}
@@ -0,0 +1,170 @@
+package umac
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+)
+
+// The marshaled state is
+//
+//	magic || version || tag size || min tag size || key fingerprint ||
+//	NH buffer || NH buffer fill || NH bytes hashed || NH state || poly state || message length
+//
+// with big-endian integers. The key fingerprint is derived with the otherwise unused
+// KDF index 255. The key is not written, but the NH and polynomial state and the buffered
+// message are functions of it and leak key material, so a state is as secret as the key.
+const (
+	stateMagic   = "umac"
+	stateVersion = 1
+	stateHeader  = len(stateMagic) + 3 + 8
+)
+
+// stateView points at the running state of a hasher, with streams NH and poly states.
+type stateView struct {
+	size      int
+	key       *Key
+	minTag    *int
+	data      *[HASH_BUF_BYTES]byte
+	nextEmpty *int
+	hashed    *int
+	state     []uint64
+	poly      []uint64
+	msgLen    *uint64
+}
+
+func (v stateView) marshal() []byte {
+	b := make([]byte, 0, stateHeader+HASH_BUF_BYTES+8+16*len(v.state)+8)
+	b = append(b, stateMagic...)
+	b = append(b, stateVersion, byte(v.size), byte(*v.minTag))
+	b = append(b, v.key.fingerprint[:]...)
+	b = append(b, v.data[:]...)
+	b = binary.BigEndian.AppendUint32(b, uint32(*v.nextEmpty))
+	b = binary.BigEndian.AppendUint32(b, uint32(*v.hashed))
+	for _, s := range v.state {
+		b = binary.BigEndian.AppendUint64(b, s)
+	}
+	for _, p := range v.poly {
+		b = binary.BigEndian.AppendUint64(b, p)
+	}
+	return binary.BigEndian.AppendUint64(b, *v.msgLen)
+}
+
+// unmarshal checks the whole state before changing the hasher.
+func (v stateView) unmarshal(b []byte) error {
+	if len(b) != stateHeader+HASH_BUF_BYTES+8+16*len(v.state)+8 ||
+		string(b[:len(stateMagic)]) != stateMagic || b[4] != stateVersion || int(b[5]) != v.size {
+		return ErrInvalidState
+	}
+	minTag := int(b[6])
+	if minTag > v.size || minTag != 0 && minTag < MinTagSize {
+		return ErrInvalidState
+	}
+	if subtle.ConstantTimeCompare(b[7:stateHeader], v.key.fingerprint[:]) != 1 {
+		return ErrKeyMismatch
+	}
+	b = b[stateHeader:]
+	data := b[:HASH_BUF_BYTES]
+	b = b[HASH_BUF_BYTES:]
+	nextEmpty := binary.BigEndian.Uint32(b)
+	hashed := binary.BigEndian.Uint32(b[4:])
+	b = b[8:]
+	msgLen := binary.BigEndian.Uint64(b[16*len(v.state):])
+	// the NH buffer holds the bytes of the current L1 block, a whole one only for the first
+	inBlock := msgLen % L1_KEY_LEN
+	if msgLen == L1_KEY_LEN {
+		inBlock = L1_KEY_LEN
+	}
+	if nextEmpty >= HASH_BUF_BYTES || hashed%HASH_BUF_BYTES != 0 || uint64(nextEmpty+hashed) != inBlock {
+		return ErrInvalidState
+	}
+
+	*v.minTag = minTag
+	copy(v.data[:], data)
+	*v.nextEmpty = int(nextEmpty)
+	*v.hashed = int(hashed)
+	for i := range v.state {
+		v.state[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	b = b[8*len(v.state):]
+	for i := range v.poly {
+		v.poly[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	*v.msgLen = msgLen
+	return nil
+}
+
+func (u *UMAC4) view() stateView {
+	return stateView{4, u.hash.key, &u.minTag, &u.hash.nh.data, &u.hash.nh.nextEmpty, &u.hash.nh.hashed,
+		u.hash.nh.state[:], u.hash.polyResult[:], &u.hash.msgLen}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, saving the running state so that
+// hashing can be resumed later, possibly in another process, under the same key.
+// The key is not included, but the state leaks key material and the buffered message:
+// it must be kept confidential and protected against modification, like the key.
+func (u *UMAC4) MarshalBinary() ([]byte, error) {
+	return u.view().marshal(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, restoring a state saved by MarshalBinary.
+// The hasher must use the same key, otherwise ErrKeyMismatch is returned.
+func (u *UMAC4) UnmarshalBinary(b []byte) error {
+	return u.view().unmarshal(b)
+}
+
+func (u *UMAC8) view() stateView {
+	return stateView{8, u.hash.key, &u.minTag, &u.hash.nh.data, &u.hash.nh.nextEmpty, &u.hash.nh.hashed,
+		u.hash.nh.state[:], u.hash.polyResult[:], &u.hash.msgLen}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, saving the running state so that
+// hashing can be resumed later, possibly in another process, under the same key.
+// The key is not included, but the state leaks key material and the buffered message:
+// it must be kept confidential and protected against modification, like the key.
+func (u *UMAC8) MarshalBinary() ([]byte, error) {
+	return u.view().marshal(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, restoring a state saved by MarshalBinary.
+// The hasher must use the same key, otherwise ErrKeyMismatch is returned.
+func (u *UMAC8) UnmarshalBinary(b []byte) error {
+	return u.view().unmarshal(b)
+}
+
+func (u *UMAC12) view() stateView {
+	return stateView{12, u.hash.key, &u.minTag, &u.hash.nh.data, &u.hash.nh.nextEmpty, &u.hash.nh.hashed,
+		u.hash.nh.state[:], u.hash.polyResult[:], &u.hash.msgLen}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, saving the running state so that
+// hashing can be resumed later, possibly in another process, under the same key.
+// The key is not included, but the state leaks key material and the buffered message:
+// it must be kept confidential and protected against modification, like the key.
+func (u *UMAC12) MarshalBinary() ([]byte, error) {
+	return u.view().marshal(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, restoring a state saved by MarshalBinary.
+// The hasher must use the same key, otherwise ErrKeyMismatch is returned.
+func (u *UMAC12) UnmarshalBinary(b []byte) error {
+	return u.view().unmarshal(b)
+}
+
+func (u *UMAC16) view() stateView {
+	return stateView{16, u.hash.key, &u.minTag, &u.hash.nh.data, &u.hash.nh.nextEmpty, &u.hash.nh.hashed,
+		u.hash.nh.state[:], u.hash.polyResult[:], &u.hash.msgLen}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, saving the running state so that
+// hashing can be resumed later, possibly in another process, under the same key.
+// The key is not included, but the state leaks key material and the buffered message:
+// it must be kept confidential and protected against modification, like the key.
+func (u *UMAC16) MarshalBinary() ([]byte, error) {
+	return u.view().marshal(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, restoring a state saved by MarshalBinary.
+// The hasher must use the same key, otherwise ErrKeyMismatch is returned.
+func (u *UMAC16) UnmarshalBinary(b []byte) error {
+	return u.view().unmarshal(b)
+}
@@ -0,0 +1,131 @@
+package umac
+
+import (
+	"bytes"
+	"encoding"
+	"hash"
+	"math/rand"
+	"testing"
+)
+
+type stateMarshaler interface {
+	hash.Hash
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+func TestMarshalBinary(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("bcdefghi")
+	data := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	news := []func(k *Key) stateMarshaler{
+		func(k *Key) stateMarshaler { return k.New4() },
+		func(k *Key) stateMarshaler { return k.New8() },
+		func(k *Key) stateMarshaler { return k.New12() },
+		func(k *Key) stateMarshaler { return k.New16() },
+	}
+	for _, newU := range news {
+		k1, _ := NewKey(key)
+		ref := newU(k1)
+		ref.Write(data)
+		want := ref.Sum(bytes.Clone(nonce))
+
+		for _, split := range []int{0, 1, 63, 64, 65, 1023, 1024, 1025, 2048, 3000, 5000} {
+			u := newU(k1)
+			u.Write(data[:split])
+			state, err := u.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// resumed from a key expanded anew, as in another process
+			k2, _ := NewKey(key)
+			r := newU(k2)
+			if err := r.UnmarshalBinary(state); err != nil {
+				t.Fatalf("UMAC-%d: split %d: %v", r.Size()*8, split, err)
+			}
+			r.Write(data[split:])
+			if tag := r.Sum(bytes.Clone(nonce)); !bytes.Equal(tag, want) {
+				t.Errorf("UMAC-%d: split %d: tag %x, expected %x", r.Size()*8, split, tag, want)
+			}
+		}
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+	other, _ := NewKey([]byte("ponmlkjihgfedcba"))
+	u := k.New8()
+	u.Write(make([]byte, 1500))
+	state, _ := u.MarshalBinary()
+	if bytes.Contains(state, []byte{byte(k.nh[0] >> 24), byte(k.nh[0] >> 16), byte(k.nh[0] >> 8), byte(k.nh[0])}) {
+		t.Error("the state contains key material")
+	}
+
+	corrupt := func(i int, v byte) []byte {
+		b := bytes.Clone(state)
+		b[i] = v
+		return b
+	}
+	for name, tc := range map[string]struct {
+		u     stateMarshaler
+		state []byte
+		err   error
+	}{
+		"other key":   {other.New8(), state, ErrKeyMismatch},
+		"other size":  {k.New16(), state, ErrInvalidState},
+		"truncated":   {k.New8(), state[:len(state)-1], ErrInvalidState},
+		"empty":       {k.New8(), nil, ErrInvalidState},
+		"magic":       {k.New8(), corrupt(0, 'x'), ErrInvalidState},
+		"version":     {k.New8(), corrupt(4, stateVersion+1), ErrInvalidState},
+		"min tag":     {k.New8(), corrupt(6, 9), ErrInvalidState},
+		"short min":   {k.New8(), corrupt(6, 2), ErrInvalidState},
+		"buffer fill": {k.New8(), corrupt(stateHeader+HASH_BUF_BYTES+3, 64), ErrInvalidState},
+		"length":      {k.New8(), corrupt(len(state)-1, 0), ErrInvalidState},
+	} {
+		before, _ := tc.u.MarshalBinary()
+		if err := tc.u.UnmarshalBinary(tc.state); err != tc.err {
+			t.Errorf("%s: err = %v, expected %v", name, err, tc.err)
+		}
+		if after, _ := tc.u.MarshalBinary(); !bytes.Equal(before, after) {
+			t.Errorf("%s: the state was changed by a failed UnmarshalBinary", name)
+		}
+	}
+}
+
+// TestUnmarshalTampered checks for every size that a state with a changed fingerprint
+// or version byte is rejected without a panic and without touching the hasher.
+func TestUnmarshalTampered(t *testing.T) {
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+	for _, u := range []stateMarshaler{k.New4(), k.New8(), k.New12(), k.New16()} {
+		u.Write(make([]byte, 1500))
+		state, _ := u.MarshalBinary()
+		type change struct {
+			i   int
+			err error
+		}
+		changes := []change{{4, ErrInvalidState}}
+		for i := 7; i < stateHeader; i++ {
+			changes = append(changes, change{i, ErrKeyMismatch})
+		}
+		for _, c := range changes {
+			b := bytes.Clone(state)
+			b[c.i] ^= 0x01
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("size %d, byte %d changed: UnmarshalBinary panicked: %v", u.Size(), c.i, r)
+					}
+				}()
+				if err := u.UnmarshalBinary(b); err != c.err {
+					t.Errorf("size %d, byte %d changed: err = %v, expected %v", u.Size(), c.i, err, c.err)
+				}
+			}()
+			if after, _ := u.MarshalBinary(); !bytes.Equal(after, state) {
+				t.Errorf("size %d, byte %d changed: the state was changed by a failed UnmarshalBinary", u.Size(), c.i)
+			}
+		}
+	}
+}
@@ -1,18 +1,12 @@
-//go:build !(ppc64 || mips || mips64)
-
-// not these BE architectures, we can assume LE and consider only byte order of LE.
-// nobody use those BE architectures anyway.
-
 package umac
 
-import (
-	"crypto/cipher"
-	"math/bits"
-)
+import "encoding/binary"
 
 const (
-	// STREAMS is Number of times hash is applied, 64 and 128 bits
+	// STREAMS is Number of times hash is applied, 32, 64, 96 and 128 bits
+	STREAMS4        = 1
 	STREAMS8        = 2
+	STREAMS12       = 3
 	STREAMS16       = 4
 	L1_KEY_LEN      = 1024 // Internal key bytes
 	L1_KEY_SHIFT    = 16   // Toeplitz key shift between streams
@@ -20,44 +14,172 @@ const (
 	HASH_BUF_BYTES  = 64   // nh_aux_hb buffer multiple
 )
 
+// nhKeyLen is the NH key length of the most streams, fewer streams use a prefix of it.
+const nhKeyLen = L1_KEY_LEN + L1_KEY_SHIFT*(STREAMS16-1)
+
+// useGeneric forces the portable NH kernels, so that tests can check
+// them against the platform specific ones.
+var useGeneric = false
+
+// region nh 4 bytes
+type nhCtx4 struct {
+	key       *[nhKeyLen / 4]uint32 // shared, read only
+	data      [HASH_BUF_BYTES]byte
+	nextEmpty int
+	hashed    int
+	state     [STREAMS4]uint64
+}
+
+func nhAux4Generic(k []uint32, d []byte, hp []uint64, dlen int) {
+	batches := dlen / 32
+
+	for batches > 0 {
+		// boundary assert
+		_ = d[31]
+		_ = k[7]
+
+		d0 := binary.LittleEndian.Uint32(d[0:])
+		d1 := binary.LittleEndian.Uint32(d[4:])
+		d2 := binary.LittleEndian.Uint32(d[8:])
+		d3 := binary.LittleEndian.Uint32(d[12:])
+		d4 := binary.LittleEndian.Uint32(d[16:])
+		d5 := binary.LittleEndian.Uint32(d[20:])
+		d6 := binary.LittleEndian.Uint32(d[24:])
+		d7 := binary.LittleEndian.Uint32(d[28:])
+
+		hp[0] += uint64(k[0]+d0)*uint64(k[4]+d4) +
+			uint64(k[1]+d1)*uint64(k[5]+d5) +
+			uint64(k[2]+d2)*uint64(k[6]+d6) +
+			uint64(k[3]+d3)*uint64(k[7]+d7)
+
+		k = k[8:]
+		d = d[32:]
+		batches--
+	}
+}
+
+func (c *nhCtx4) transform(buf []byte) {
+	nhAux4(c.key[c.hashed/4:], buf, c.state[:], len(buf))
+}
+
+func (c *nhCtx4) reset() {
+	c.nextEmpty = 0
+	c.hashed = 0
+	c.state[0] = 0
+}
+
+func (c *nhCtx4) init(key *[nhKeyLen / 4]uint32) {
+	c.key = key
+	c.reset()
+}
+
+func (c *nhCtx4) update(buf []byte) {
+	j := c.nextEmpty
+	n := len(buf)
+	if (j + n) >= HASH_BUF_BYTES {
+		if j != 0 {
+			i := HASH_BUF_BYTES - j
+			copy(c.data[j:], buf[:i])
+			c.transform(c.data[:])
+			n -= i
+			buf = buf[i:]
+			c.hashed += HASH_BUF_BYTES
+		}
+		if n >= HASH_BUF_BYTES {
+			i := n & ^(HASH_BUF_BYTES - 1)
+			c.transform(buf[:i])
+			n -= i
+			buf = buf[i:]
+			c.hashed += i
+		}
+		j = 0
+	}
+	copy(c.data[j:], buf)
+	c.nextEmpty = j + n
+}
+
+func (c *nhCtx4) final(result []uint64) {
+	_ = result[0]
+
+	if c.nextEmpty != 0 {
+		nhLen := (c.nextEmpty + (L1_PAD_BOUNDARY - 1)) & ^(L1_PAD_BOUNDARY - 1)
+		copy(c.data[c.nextEmpty:], make([]byte, nhLen-c.nextEmpty))
+		c.transform(c.data[:nhLen])
+		c.hashed += c.nextEmpty
+	} else if c.hashed == 0 {
+		copy(c.data[:], make([]byte, L1_PAD_BOUNDARY))
+		c.transform(c.data[:L1_PAD_BOUNDARY])
+	}
+
+	nbits := c.hashed << 3
+	result[0] = c.state[0] + uint64(nbits)
+	c.reset()
+}
+
+func (c *nhCtx4) hash(buf []byte, paddedLen, unpaddedLen int, result []uint64) {
+	nbits := uint64(unpaddedLen << 3)
+	result[0] = nbits
+	nhAux4(c.key[:], buf, result, paddedLen)
+}
+
+// hashShort hashes a message of at most L1_KEY_LEN bytes in one go,
+// only the trailing partial block is copied out for padding.
+func (c *nhCtx4) hashShort(msg []byte, result []uint64) {
+	full := len(msg) & ^(L1_PAD_BOUNDARY - 1)
+	c.hash(msg, full, len(msg), result)
+	if full != len(msg) || full == 0 {
+		var tail [L1_PAD_BOUNDARY]byte
+		copy(tail[:], msg[full:])
+		nhAux4(c.key[full/4:], tail[:], result, L1_PAD_BOUNDARY)
+	}
+}
+
+//endregion
+
 // region nh 8 bytes
 type nhCtx8 struct {
-	key       [L1_KEY_LEN + L1_KEY_SHIFT*(STREAMS8-1)]byte
+	key       *[nhKeyLen / 4]uint32 // shared, read only
 	data      [HASH_BUF_BYTES]byte
 	nextEmpty int
 	hashed    int
 	state     [STREAMS8]uint64
 }
 
-func nhAux8(k, d []uint32, hp []uint64, dlen int) {
+func nhAux8Generic(k []uint32, d []byte, hp []uint64, dlen int) {
 	batches := dlen / 32
 
-	kache := k[0:4]
 	for batches > 0 {
 		// boundary assert
-		_ = d[7]
+		_ = d[31]
 		_ = k[11]
 
-		hp[0] += uint64(kache[0]+d[0])*uint64(k[4]+d[4]) +
-			uint64(kache[1]+d[1])*uint64(k[5]+d[5]) +
-			uint64(kache[2]+d[2])*uint64(k[6]+d[6]) +
-			uint64(kache[3]+d[3])*uint64(k[7]+d[7])
-
-		hp[1] += uint64(k[4]+d[0])*uint64(k[8]+d[4]) +
-			uint64(k[5]+d[1])*uint64(k[9]+d[5]) +
-			uint64(k[6]+d[2])*uint64(k[10]+d[6]) +
-			uint64(k[7]+d[3])*uint64(k[11]+d[7])
-
-		kache = k[8:12]
+		d0 := binary.LittleEndian.Uint32(d[0:])
+		d1 := binary.LittleEndian.Uint32(d[4:])
+		d2 := binary.LittleEndian.Uint32(d[8:])
+		d3 := binary.LittleEndian.Uint32(d[12:])
+		d4 := binary.LittleEndian.Uint32(d[16:])
+		d5 := binary.LittleEndian.Uint32(d[20:])
+		d6 := binary.LittleEndian.Uint32(d[24:])
+		d7 := binary.LittleEndian.Uint32(d[28:])
+
+		hp[0] += uint64(k[0]+d0)*uint64(k[4]+d4) +
+			uint64(k[1]+d1)*uint64(k[5]+d5) +
+			uint64(k[2]+d2)*uint64(k[6]+d6) +
+			uint64(k[3]+d3)*uint64(k[7]+d7)
+
+		hp[1] += uint64(k[4]+d0)*uint64(k[8]+d4) +
+			uint64(k[5]+d1)*uint64(k[9]+d5) +
+			uint64(k[6]+d2)*uint64(k[10]+d6) +
+			uint64(k[7]+d3)*uint64(k[11]+d7)
 
 		k = k[8:]
-		d = d[8:]
+		d = d[32:]
 		batches--
 	}
 }
 
 func (c *nhCtx8) transform(buf []byte) {
-	nhAux8(toUint32(c.key[c.hashed:]), toUint32(buf), c.state[:], len(buf))
+	nhAux8(c.key[c.hashed/4:], buf, c.state[:], len(buf))
 }
 
 func (c *nhCtx8) reset() {
@@ -67,12 +189,8 @@ func (c *nhCtx8) reset() {
 	c.state[1] = 0
 }
 
-func (c *nhCtx8) init(cip cipher.Block) {
-	kdf(cip, 1, c.key[:])
-	array := toUint32(c.key[:])
-	for i := range array {
-		array[i] = bits.ReverseBytes32(array[i])
-	}
+func (c *nhCtx8) init(key *[nhKeyLen / 4]uint32) {
+	c.key = key
 	c.reset()
 }
 
@@ -124,56 +242,208 @@ func (c *nhCtx8) hash(buf []byte, paddedLen, unpaddedLen int, result []uint64) {
 	nbits := uint64(unpaddedLen << 3)
 	result[0] = nbits
 	result[1] = nbits
-	nhAux8(toUint32(c.key[:]), toUint32(buf), result, paddedLen)
+	nhAux8(c.key[:], buf, result, paddedLen)
+}
+
+// hashShort hashes a message of at most L1_KEY_LEN bytes in one go,
+// only the trailing partial block is copied out for padding.
+func (c *nhCtx8) hashShort(msg []byte, result []uint64) {
+	full := len(msg) & ^(L1_PAD_BOUNDARY - 1)
+	c.hash(msg, full, len(msg), result)
+	if full != len(msg) || full == 0 {
+		var tail [L1_PAD_BOUNDARY]byte
+		copy(tail[:], msg[full:])
+		nhAux8(c.key[full/4:], tail[:], result, L1_PAD_BOUNDARY)
+	}
+}
+
+//endregion
+
+// region nh 12 bytes
+type nhCtx12 struct {
+	key       *[nhKeyLen / 4]uint32 // shared, read only
+	data      [HASH_BUF_BYTES]byte
+	nextEmpty int
+	hashed    int
+	state     [STREAMS12]uint64
+}
+
+func nhAux12Generic(k []uint32, d []byte, hp []uint64, dlen int) {
+	batches := dlen / 32
+
+	for batches > 0 {
+		// boundary assert
+		_ = d[31]
+		_ = k[15]
+
+		d0 := binary.LittleEndian.Uint32(d[0:])
+		d1 := binary.LittleEndian.Uint32(d[4:])
+		d2 := binary.LittleEndian.Uint32(d[8:])
+		d3 := binary.LittleEndian.Uint32(d[12:])
+		d4 := binary.LittleEndian.Uint32(d[16:])
+		d5 := binary.LittleEndian.Uint32(d[20:])
+		d6 := binary.LittleEndian.Uint32(d[24:])
+		d7 := binary.LittleEndian.Uint32(d[28:])
+
+		hp[0] += uint64(k[0]+d0)*uint64(k[4]+d4) +
+			uint64(k[1]+d1)*uint64(k[5]+d5) +
+			uint64(k[2]+d2)*uint64(k[6]+d6) +
+			uint64(k[3]+d3)*uint64(k[7]+d7)
+
+		hp[1] += uint64(k[4]+d0)*uint64(k[8]+d4) +
+			uint64(k[5]+d1)*uint64(k[9]+d5) +
+			uint64(k[6]+d2)*uint64(k[10]+d6) +
+			uint64(k[7]+d3)*uint64(k[11]+d7)
+
+		hp[2] += uint64(k[8]+d0)*uint64(k[12]+d4) +
+			uint64(k[9]+d1)*uint64(k[13]+d5) +
+			uint64(k[10]+d2)*uint64(k[14]+d6) +
+			uint64(k[11]+d3)*uint64(k[15]+d7)
+
+		k = k[8:]
+		d = d[32:]
+		batches--
+	}
+}
+
+func (c *nhCtx12) transform(buf []byte) {
+	nhAux12(c.key[c.hashed/4:], buf, c.state[:], len(buf))
+}
+
+func (c *nhCtx12) reset() {
+	c.nextEmpty = 0
+	c.hashed = 0
+	c.state[0] = 0
+	c.state[1] = 0
+	c.state[2] = 0
+}
+
+func (c *nhCtx12) init(key *[nhKeyLen / 4]uint32) {
+	c.key = key
+	c.reset()
+}
+
+func (c *nhCtx12) update(buf []byte) {
+	j := c.nextEmpty
+	n := len(buf)
+	if (j + n) >= HASH_BUF_BYTES {
+		if j != 0 {
+			i := HASH_BUF_BYTES - j
+			copy(c.data[j:], buf[:i])
+			c.transform(c.data[:])
+			n -= i
+			buf = buf[i:]
+			c.hashed += HASH_BUF_BYTES
+		}
+		if n >= HASH_BUF_BYTES {
+			i := n & ^(HASH_BUF_BYTES - 1)
+			c.transform(buf[:i])
+			n -= i
+			buf = buf[i:]
+			c.hashed += i
+		}
+		j = 0
+	}
+	copy(c.data[j:], buf)
+	c.nextEmpty = j + n
+}
+
+func (c *nhCtx12) final(result []uint64) {
+	_ = result[2]
+
+	if c.nextEmpty != 0 {
+		nhLen := (c.nextEmpty + (L1_PAD_BOUNDARY - 1)) & ^(L1_PAD_BOUNDARY - 1)
+		copy(c.data[c.nextEmpty:], make([]byte, nhLen-c.nextEmpty))
+		c.transform(c.data[:nhLen])
+		c.hashed += c.nextEmpty
+	} else if c.hashed == 0 {
+		copy(c.data[:], make([]byte, L1_PAD_BOUNDARY))
+		c.transform(c.data[:L1_PAD_BOUNDARY])
+	}
+
+	nbits := c.hashed << 3
+	result[0] = c.state[0] + uint64(nbits)
+	result[1] = c.state[1] + uint64(nbits)
+	result[2] = c.state[2] + uint64(nbits)
+	c.reset()
+}
+
+func (c *nhCtx12) hash(buf []byte, paddedLen, unpaddedLen int, result []uint64) {
+	nbits := uint64(unpaddedLen << 3)
+	result[0] = nbits
+	result[1] = nbits
+	result[2] = nbits
+	nhAux12(c.key[:], buf, result, paddedLen)
+}
+
+// hashShort hashes a message of at most L1_KEY_LEN bytes in one go,
+// only the trailing partial block is copied out for padding.
+func (c *nhCtx12) hashShort(msg []byte, result []uint64) {
+	full := len(msg) & ^(L1_PAD_BOUNDARY - 1)
+	c.hash(msg, full, len(msg), result)
+	if full != len(msg) || full == 0 {
+		var tail [L1_PAD_BOUNDARY]byte
+		copy(tail[:], msg[full:])
+		nhAux12(c.key[full/4:], tail[:], result, L1_PAD_BOUNDARY)
+	}
 }
 
 //endregion
 
 // region nh 16 bytes
 type nhCtx16 struct {
-	key       [L1_KEY_LEN + L1_KEY_SHIFT*(STREAMS16-1)]byte
+	key       *[nhKeyLen / 4]uint32 // shared, read only
 	data      [HASH_BUF_BYTES]byte
 	nextEmpty int
 	hashed    int
 	state     [STREAMS16]uint64
 }
 
-func nhAux16(k, d []uint32, hp []uint64, dlen int) {
+func nhAux16Generic(k []uint32, d []byte, hp []uint64, dlen int) {
 	batches := dlen / 32
 
 	for batches > 0 {
 		// boundary assert
-		_ = d[7]
+		_ = d[31]
 		_ = k[19]
 
-		hp[0] += uint64(k[0]+d[0])*uint64(k[4]+d[4]) +
-			uint64(k[1]+d[1])*uint64(k[5]+d[5]) +
-			uint64(k[2]+d[2])*uint64(k[6]+d[6]) +
-			uint64(k[3]+d[3])*uint64(k[7]+d[7])
-
-		hp[1] += uint64(k[4]+d[0])*uint64(k[8]+d[4]) +
-			uint64(k[5]+d[1])*uint64(k[9]+d[5]) +
-			uint64(k[6]+d[2])*uint64(k[10]+d[6]) +
-			uint64(k[7]+d[3])*uint64(k[11]+d[7])
-
-		hp[2] += uint64(k[8]+d[0])*uint64(k[12]+d[4]) +
-			uint64(k[9]+d[1])*uint64(k[13]+d[5]) +
-			uint64(k[10]+d[2])*uint64(k[14]+d[6]) +
-			uint64(k[11]+d[3])*uint64(k[15]+d[7])
-
-		hp[3] += uint64(k[12]+d[0])*uint64(k[16]+d[4]) +
-			uint64(k[13]+d[1])*uint64(k[17]+d[5]) +
-			uint64(k[14]+d[2])*uint64(k[18]+d[6]) +
-			uint64(k[15]+d[3])*uint64(k[19]+d[7])
+		d0 := binary.LittleEndian.Uint32(d[0:])
+		d1 := binary.LittleEndian.Uint32(d[4:])
+		d2 := binary.LittleEndian.Uint32(d[8:])
+		d3 := binary.LittleEndian.Uint32(d[12:])
+		d4 := binary.LittleEndian.Uint32(d[16:])
+		d5 := binary.LittleEndian.Uint32(d[20:])
+		d6 := binary.LittleEndian.Uint32(d[24:])
+		d7 := binary.LittleEndian.Uint32(d[28:])
+
+		hp[0] += uint64(k[0]+d0)*uint64(k[4]+d4) +
+			uint64(k[1]+d1)*uint64(k[5]+d5) +
+			uint64(k[2]+d2)*uint64(k[6]+d6) +
+			uint64(k[3]+d3)*uint64(k[7]+d7)
+
+		hp[1] += uint64(k[4]+d0)*uint64(k[8]+d4) +
+			uint64(k[5]+d1)*uint64(k[9]+d5) +
+			uint64(k[6]+d2)*uint64(k[10]+d6) +
+			uint64(k[7]+d3)*uint64(k[11]+d7)
+
+		hp[2] += uint64(k[8]+d0)*uint64(k[12]+d4) +
+			uint64(k[9]+d1)*uint64(k[13]+d5) +
+			uint64(k[10]+d2)*uint64(k[14]+d6) +
+			uint64(k[11]+d3)*uint64(k[15]+d7)
+
+		hp[3] += uint64(k[12]+d0)*uint64(k[16]+d4) +
+			uint64(k[13]+d1)*uint64(k[17]+d5) +
+			uint64(k[14]+d2)*uint64(k[18]+d6) +
+			uint64(k[15]+d3)*uint64(k[19]+d7)
 
 		k = k[8:]
-		d = d[8:]
+		d = d[32:]
 		batches--
 	}
 }
 
 func (c *nhCtx16) transform(buf []byte) {
-	nhAux16(toUint32(c.key[c.hashed:]), toUint32(buf), c.state[:], len(buf))
+	nhAux16(c.key[c.hashed/4:], buf, c.state[:], len(buf))
 }
 
 func (c *nhCtx16) reset() {
@@ -185,12 +455,8 @@ func (c *nhCtx16) reset() {
 	c.state[3] = 0
 }
 
-func (c *nhCtx16) init(cip cipher.Block) {
-	kdf(cip, 1, c.key[:])
-	array := toUint32(c.key[:])
-	for i := range array {
-		array[i] = bits.ReverseBytes32(array[i])
-	}
+func (c *nhCtx16) init(key *[nhKeyLen / 4]uint32) {
+	c.key = key
 	c.reset()
 }
 
@@ -244,7 +510,19 @@ func (c *nhCtx16) hash(buf []byte, paddedLen, unpaddedLen int, result []uint64)
 	result[1] = nbits
 	result[2] = nbits
 	result[3] = nbits
-	nhAux16(toUint32(c.key[:]), toUint32(buf), result, paddedLen)
+	nhAux16(c.key[:], buf, result, paddedLen)
+}
+
+// hashShort hashes a message of at most L1_KEY_LEN bytes in one go,
+// only the trailing partial block is copied out for padding.
+func (c *nhCtx16) hashShort(msg []byte, result []uint64) {
+	full := len(msg) & ^(L1_PAD_BOUNDARY - 1)
+	c.hash(msg, full, len(msg), result)
+	if full != len(msg) || full == 0 {
+		var tail [L1_PAD_BOUNDARY]byte
+		copy(tail[:], msg[full:])
+		nhAux16(c.key[full/4:], tail[:], result, L1_PAD_BOUNDARY)
+	}
 }
 
 //endregion
@@ -0,0 +1,137 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func nh1SSE2(k *uint32, d *byte, n int, hp *uint64)
+// Adds NH of n bytes at d with key k to *hp, n must be a multiple of 32.
+TEXT ·nh1SSE2(SB), NOSPLIT, $0-32
+	MOVQ k+0(FP), SI
+	MOVQ d+8(FP), DI
+	MOVQ n+16(FP), CX
+	MOVQ hp+24(FP), DX
+
+	PXOR X0, X0
+	SHRQ $5, CX
+	JZ   sse2Reduce
+
+sse2Loop:
+	MOVOU (SI), X1
+	MOVOU 16(SI), X2
+	MOVOU (DI), X3
+	MOVOU 16(DI), X4
+	PADDL X3, X1     // k[0:4] + d[0:4]
+	PADDL X4, X2     // k[4:8] + d[4:8]
+	MOVO  X1, X3
+	MOVO  X2, X4
+	PSRLQ $32, X3
+	PSRLQ $32, X4
+	PMULULQ X1, X2   // products of words 0 and 2
+	PMULULQ X3, X4   // products of words 1 and 3
+	PADDQ X2, X0
+	PADDQ X4, X0
+	ADDQ  $32, SI
+	ADDQ  $32, DI
+	DECQ  CX
+	JNZ   sse2Loop
+
+sse2Reduce:
+	PSHUFD $0x4e, X0, X1
+	PADDQ  X1, X0
+	MOVQ   X0, AX
+	ADDQ   AX, (DX)
+	RET
+
+// func nh1AVX2(k *uint32, d *byte, n int, hp *uint64)
+// Same as nh1SSE2, processing 64 bytes per iteration.
+TEXT ·nh1AVX2(SB), NOSPLIT, $0-32
+	MOVQ k+0(FP), SI
+	MOVQ d+8(FP), DI
+	MOVQ n+16(FP), CX
+	MOVQ hp+24(FP), DX
+
+	VPXOR Y0, Y0, Y0
+	MOVQ  CX, BX
+	SHRQ  $6, CX
+	JZ    avx1Tail
+
+avx1Loop:
+	VMOVDQU    (SI), Y1
+	VMOVDQU    32(SI), Y2
+	VPADDD     (DI), Y1, Y1      // [a | b] of the first 32 bytes
+	VPADDD     32(DI), Y2, Y2    // [a | b] of the second 32 bytes
+	VPERM2I128 $0x20, Y2, Y1, Y3 // both a
+	VPERM2I128 $0x31, Y2, Y1, Y4 // both b
+	VPMULUDQ   Y3, Y4, Y5
+	VPSRLQ     $32, Y3, Y3
+	VPSRLQ     $32, Y4, Y4
+	VPMULUDQ   Y3, Y4, Y6
+	VPADDQ     Y5, Y0, Y0
+	VPADDQ     Y6, Y0, Y0
+	ADDQ       $64, SI
+	ADDQ       $64, DI
+	DECQ       CX
+	JNZ        avx1Loop
+
+avx1Tail:
+	TESTQ    $32, BX
+	JZ       avx1Reduce
+	VMOVDQU  (SI), X1
+	VMOVDQU  16(SI), X2
+	VPADDD   (DI), X1, X1
+	VPADDD   16(DI), X2, X2
+	VPMULUDQ X1, X2, X3
+	VPSRLQ   $32, X1, X1
+	VPSRLQ   $32, X2, X2
+	VPMULUDQ X1, X2, X4
+	VPADDQ   Y3, Y0, Y0          // upper halves of Y3 and Y4 are zero
+	VPADDQ   Y4, Y0, Y0
+
+avx1Reduce:
+	VEXTRACTI128 $1, Y0, X1
+	VPADDQ       X1, X0, X0
+	VPSHUFD      $0x4e, X0, X1
+	VPADDQ       X1, X0, X0
+	VZEROUPPER
+	MOVQ         X0, AX
+	ADDQ         AX, (DX)
+	RET
+
+// func nh2AVX2(k *uint32, d *byte, n int, hp *uint64)
+// Adds NH of two consecutive streams to hp[0] and hp[1], the second stream
+// uses the key shifted by L1_KEY_SHIFT, one in each 128-bit lane.
+TEXT ·nh2AVX2(SB), NOSPLIT, $0-32
+	MOVQ k+0(FP), SI
+	MOVQ d+8(FP), DI
+	MOVQ n+16(FP), CX
+	MOVQ hp+24(FP), DX
+
+	VPXOR Y0, Y0, Y0
+	SHRQ  $5, CX
+	JZ    avx2Reduce
+
+avx2Loop:
+	VBROADCASTI128 (DI), Y1   // [d[0:4] | d[0:4]]
+	VBROADCASTI128 16(DI), Y2 // [d[4:8] | d[4:8]]
+	VPADDD         (SI), Y1, Y1   // [k[0:4] | k[4:8]] + d[0:4]
+	VPADDD         16(SI), Y2, Y2 // [k[4:8] | k[8:12]] + d[4:8]
+	VPMULUDQ       Y1, Y2, Y3
+	VPSRLQ         $32, Y1, Y1
+	VPSRLQ         $32, Y2, Y2
+	VPMULUDQ       Y1, Y2, Y4
+	VPADDQ         Y3, Y0, Y0
+	VPADDQ         Y4, Y0, Y0
+	ADDQ           $32, SI
+	ADDQ           $32, DI
+	DECQ           CX
+	JNZ            avx2Loop
+
+avx2Reduce:
+	VPSHUFD      $0x4e, Y0, Y1
+	VPADDQ       Y1, Y0, Y0
+	VEXTRACTI128 $1, Y0, X1
+	VZEROUPPER
+	MOVQ         X0, AX
+	ADDQ         AX, (DX)
+	MOVQ         X1, AX
+	ADDQ         AX, 8(DX)
+	RET
@@ -0,0 +1,17 @@
+//go:build !purego
+
+package umac
+
+func init() {
+	useFlags := func(avx2, sse2 bool) func() func() {
+		return func() func() {
+			oldAVX2, oldSSE2 := useAVX2, useSSE2
+			useAVX2, useSSE2 = avx2 && oldAVX2, sse2 && oldSSE2
+			return func() { useAVX2, useSSE2 = oldAVX2, oldSSE2 }
+		}
+	}
+	nhImpls = append(nhImpls,
+		nhImpl{"sse2", useFlags(false, true)},
+		nhImpl{"le", useFlags(false, false)},
+	)
+}
@@ -0,0 +1,76 @@
+//go:build !purego
+
+package umac
+
+import "golang.org/x/sys/cpu"
+
+var useNEON = cpu.ARM64.HasASIMD
+
+//go:noescape
+func nh1NEON(k *uint32, d *byte, n int, hp *uint64)
+
+//go:noescape
+func nh2NEON(k *uint32, d *byte, n int, hp *uint64)
+
+// nhAsm runs the assembly kernels over streams, two at a time.
+func nhAsm(k []uint32, d []byte, hp []uint64, dlen, streams int) {
+	if dlen <= 0 {
+		return
+	}
+	// the kernels read this far, keep them inside the slices
+	_ = k[dlen/4+4*(streams-1)-1]
+	_ = d[dlen-1]
+	_ = hp[streams-1]
+
+	s := 0
+	for ; s+2 <= streams; s += 2 {
+		nh2NEON(&k[4*s], &d[0], dlen, &hp[s])
+	}
+	if s < streams {
+		nh1NEON(&k[4*s], &d[0], dlen, &hp[s])
+	}
+}
+
+func nhAux4(k []uint32, d []byte, hp []uint64, dlen int) {
+	switch {
+	case useGeneric:
+		nhAux4Generic(k, d, hp, dlen)
+	case useNEON:
+		nhAsm(k, d, hp, dlen, STREAMS4)
+	default:
+		nhAux4LE(k, d, hp, dlen)
+	}
+}
+
+func nhAux8(k []uint32, d []byte, hp []uint64, dlen int) {
+	switch {
+	case useGeneric:
+		nhAux8Generic(k, d, hp, dlen)
+	case useNEON:
+		nhAsm(k, d, hp, dlen, STREAMS8)
+	default:
+		nhAux8LE(k, d, hp, dlen)
+	}
+}
+
+func nhAux12(k []uint32, d []byte, hp []uint64, dlen int) {
+	switch {
+	case useGeneric:
+		nhAux12Generic(k, d, hp, dlen)
+	case useNEON:
+		nhAsm(k, d, hp, dlen, STREAMS12)
+	default:
+		nhAux12LE(k, d, hp, dlen)
+	}
+}
+
+func nhAux16(k []uint32, d []byte, hp []uint64, dlen int) {
+	switch {
+	case useGeneric:
+		nhAux16Generic(k, d, hp, dlen)
+	case useNEON:
+		nhAsm(k, d, hp, dlen, STREAMS16)
+	default:
+		nhAux16LE(k, d, hp, dlen)
+	}
+}
@@ -0,0 +1,69 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func nh1NEON(k *uint32, d *byte, n int, hp *uint64)
+// Adds NH of n bytes at d with key k to *hp, n must be a multiple of 32.
+TEXT ·nh1NEON(SB), NOSPLIT, $0-32
+	MOVD k+0(FP), R0
+	MOVD d+8(FP), R1
+	MOVD n+16(FP), R2
+	MOVD hp+24(FP), R3
+
+	VEOR V20.B16, V20.B16, V20.B16
+	LSR  $5, R2
+	CBZ  R2, nh1Reduce
+
+nh1Loop:
+	VLD1.P  32(R0), [V0.S4, V1.S4]
+	VLD1.P  32(R1), [V2.S4, V3.S4]
+	VADD    V2.S4, V0.S4, V4.S4 // k[0:4] + d[0:4]
+	VADD    V3.S4, V1.S4, V5.S4 // k[4:8] + d[4:8]
+	VUMLAL  V4.S2, V5.S2, V20.D2
+	VUMLAL2 V4.S4, V5.S4, V20.D2
+	SUB     $1, R2
+	CBNZ    R2, nh1Loop
+
+nh1Reduce:
+	VADDP V20.D2, V20.D2, V20.D2
+	VMOV  V20.D[0], R4
+	MOVD  (R3), R5
+	ADD   R4, R5
+	MOVD  R5, (R3)
+	RET
+
+// func nh2NEON(k *uint32, d *byte, n int, hp *uint64)
+// Adds NH of two consecutive streams to hp[0] and hp[1], the second stream
+// uses the key shifted by L1_KEY_SHIFT.
+TEXT ·nh2NEON(SB), NOSPLIT, $0-32
+	MOVD k+0(FP), R0
+	MOVD d+8(FP), R1
+	MOVD n+16(FP), R2
+	MOVD hp+24(FP), R3
+
+	VEOR V20.B16, V20.B16, V20.B16
+	VEOR V21.B16, V21.B16, V21.B16
+	LSR  $5, R2
+	CBZ  R2, nh2Reduce
+
+nh2Loop:
+	VLD1    (R0), [V0.S4, V1.S4, V2.S4]
+	ADD     $32, R0
+	VLD1.P  32(R1), [V3.S4, V4.S4]
+	VADD    V3.S4, V0.S4, V5.S4  // k[0:4] + d[0:4]
+	VADD    V4.S4, V1.S4, V6.S4  // k[4:8] + d[4:8]
+	VADD    V3.S4, V1.S4, V7.S4  // k[4:8] + d[0:4]
+	VADD    V4.S4, V2.S4, V16.S4 // k[8:12] + d[4:8]
+	VUMLAL  V5.S2, V6.S2, V20.D2
+	VUMLAL2 V5.S4, V6.S4, V20.D2
+	VUMLAL  V7.S2, V16.S2, V21.D2
+	VUMLAL2 V7.S4, V16.S4, V21.D2
+	SUB     $1, R2
+	CBNZ    R2, nh2Loop
+
+nh2Reduce:
+	VADDP V21.D2, V20.D2, V20.D2 // [sum of V20, sum of V21]
+	VLD1  (R3), [V22.D2]
+	VADD  V20.D2, V22.D2, V22.D2
+	VST1  [V22.D2], (R3)
+	RET
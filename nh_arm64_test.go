@@ -0,0 +1,11 @@
+//go:build !purego
+
+package umac
+
+func init() {
+	nhImpls = append(nhImpls, nhImpl{"le", func() func() {
+		old := useNEON
+		useNEON = false
+		return func() { useNEON = old }
+	}})
+}
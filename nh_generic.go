@@ -0,0 +1,19 @@
+//go:build !(386 || amd64 || arm64 || loong64 || ppc64le || wasm) || purego
+
+package umac
+
+func nhAux4(k []uint32, d []byte, hp []uint64, dlen int) {
+	nhAux4Generic(k, d, hp, dlen)
+}
+
+func nhAux8(k []uint32, d []byte, hp []uint64, dlen int) {
+	nhAux8Generic(k, d, hp, dlen)
+}
+
+func nhAux12(k []uint32, d []byte, hp []uint64, dlen int) {
+	nhAux12Generic(k, d, hp, dlen)
+}
+
+func nhAux16(k []uint32, d []byte, hp []uint64, dlen int) {
+	nhAux16Generic(k, d, hp, dlen)
+}
@@ -0,0 +1,119 @@
+//go:build (386 || amd64 || arm64 || loong64 || ppc64le || wasm) && !purego
+
+// On these little-endian architectures, unaligned loads are fine, so the
+// message is reinterpreted as 32-bit words in place instead of decoded.
+
+package umac
+
+func nhAux4LE(k []uint32, buf []byte, hp []uint64, dlen int) {
+	d := toUint32(buf)
+	batches := dlen / 32
+
+	for batches > 0 {
+		// boundary assert
+		_ = d[7]
+		_ = k[7]
+
+		hp[0] += uint64(k[0]+d[0])*uint64(k[4]+d[4]) +
+			uint64(k[1]+d[1])*uint64(k[5]+d[5]) +
+			uint64(k[2]+d[2])*uint64(k[6]+d[6]) +
+			uint64(k[3]+d[3])*uint64(k[7]+d[7])
+
+		k = k[8:]
+		d = d[8:]
+		batches--
+	}
+}
+
+func nhAux8LE(k []uint32, buf []byte, hp []uint64, dlen int) {
+	d := toUint32(buf)
+	batches := dlen / 32
+
+	kache := k[0:4]
+	for batches > 0 {
+		// boundary assert
+		_ = d[7]
+		_ = k[11]
+
+		hp[0] += uint64(kache[0]+d[0])*uint64(k[4]+d[4]) +
+			uint64(kache[1]+d[1])*uint64(k[5]+d[5]) +
+			uint64(kache[2]+d[2])*uint64(k[6]+d[6]) +
+			uint64(kache[3]+d[3])*uint64(k[7]+d[7])
+
+		hp[1] += uint64(k[4]+d[0])*uint64(k[8]+d[4]) +
+			uint64(k[5]+d[1])*uint64(k[9]+d[5]) +
+			uint64(k[6]+d[2])*uint64(k[10]+d[6]) +
+			uint64(k[7]+d[3])*uint64(k[11]+d[7])
+
+		kache = k[8:12]
+
+		k = k[8:]
+		d = d[8:]
+		batches--
+	}
+}
+
+func nhAux12LE(k []uint32, buf []byte, hp []uint64, dlen int) {
+	d := toUint32(buf)
+	batches := dlen / 32
+
+	for batches > 0 {
+		// boundary assert
+		_ = d[7]
+		_ = k[15]
+
+		hp[0] += uint64(k[0]+d[0])*uint64(k[4]+d[4]) +
+			uint64(k[1]+d[1])*uint64(k[5]+d[5]) +
+			uint64(k[2]+d[2])*uint64(k[6]+d[6]) +
+			uint64(k[3]+d[3])*uint64(k[7]+d[7])
+
+		hp[1] += uint64(k[4]+d[0])*uint64(k[8]+d[4]) +
+			uint64(k[5]+d[1])*uint64(k[9]+d[5]) +
+			uint64(k[6]+d[2])*uint64(k[10]+d[6]) +
+			uint64(k[7]+d[3])*uint64(k[11]+d[7])
+
+		hp[2] += uint64(k[8]+d[0])*uint64(k[12]+d[4]) +
+			uint64(k[9]+d[1])*uint64(k[13]+d[5]) +
+			uint64(k[10]+d[2])*uint64(k[14]+d[6]) +
+			uint64(k[11]+d[3])*uint64(k[15]+d[7])
+
+		k = k[8:]
+		d = d[8:]
+		batches--
+	}
+}
+
+func nhAux16LE(k []uint32, buf []byte, hp []uint64, dlen int) {
+	d := toUint32(buf)
+	batches := dlen / 32
+
+	for batches > 0 {
+		// boundary assert
+		_ = d[7]
+		_ = k[19]
+
+		hp[0] += uint64(k[0]+d[0])*uint64(k[4]+d[4]) +
+			uint64(k[1]+d[1])*uint64(k[5]+d[5]) +
+			uint64(k[2]+d[2])*uint64(k[6]+d[6]) +
+			uint64(k[3]+d[3])*uint64(k[7]+d[7])
+
+		hp[1] += uint64(k[4]+d[0])*uint64(k[8]+d[4]) +
+			uint64(k[5]+d[1])*uint64(k[9]+d[5]) +
+			uint64(k[6]+d[2])*uint64(k[10]+d[6]) +
+			uint64(k[7]+d[3])*uint64(k[11]+d[7])
+
+		hp[2] += uint64(k[8]+d[0])*uint64(k[12]+d[4]) +
+			uint64(k[9]+d[1])*uint64(k[13]+d[5]) +
+			uint64(k[10]+d[2])*uint64(k[14]+d[6]) +
+			uint64(k[11]+d[3])*uint64(k[15]+d[7])
+
+		hp[3] += uint64(k[12]+d[0])*uint64(k[16]+d[4]) +
+			uint64(k[13]+d[1])*uint64(k[17]+d[5]) +
+			uint64(k[14]+d[2])*uint64(k[18]+d[6]) +
+			uint64(k[15]+d[3])*uint64(k[19]+d[7])
+
+		k = k[8:]
+		d = d[8:]
+		batches--
+	}
+}
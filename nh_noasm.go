@@ -0,0 +1,35 @@
+//go:build (386 || loong64 || ppc64le || wasm) && !purego
+
+package umac
+
+func nhAux4(k []uint32, d []byte, hp []uint64, dlen int) {
+	if useGeneric {
+		nhAux4Generic(k, d, hp, dlen)
+		return
+	}
+	nhAux4LE(k, d, hp, dlen)
+}
+
+func nhAux8(k []uint32, d []byte, hp []uint64, dlen int) {
+	if useGeneric {
+		nhAux8Generic(k, d, hp, dlen)
+		return
+	}
+	nhAux8LE(k, d, hp, dlen)
+}
+
+func nhAux12(k []uint32, d []byte, hp []uint64, dlen int) {
+	if useGeneric {
+		nhAux12Generic(k, d, hp, dlen)
+		return
+	}
+	nhAux12LE(k, d, hp, dlen)
+}
+
+func nhAux16(k []uint32, d []byte, hp []uint64, dlen int) {
+	if useGeneric {
+		nhAux16Generic(k, d, hp, dlen)
+		return
+	}
+	nhAux16LE(k, d, hp, dlen)
+}
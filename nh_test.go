@@ -0,0 +1,102 @@
+package umac
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// nhImpl is a set of NH kernels, use switches to it and returns a function restoring the previous one.
+type nhImpl struct {
+	name string
+	use  func() (restore func())
+}
+
+// nhImpls lists the NH kernels available on this platform, architecture specific tests add theirs.
+var nhImpls = []nhImpl{
+	{"default", func() func() { return func() {} }},
+	{"generic", func() func() {
+		old := useGeneric
+		useGeneric = true
+		return func() { useGeneric = old }
+	}},
+}
+
+// TestImplementations runs the vector tests with every NH implementation.
+func TestImplementations(t *testing.T) {
+	for _, impl := range nhImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			defer impl.use()()
+
+			TestUMAC16_SSHCase(t)
+			TestUMAC(t)
+			TestRFC4418(t)
+			TestHashRFC4418(t)
+			TestKeyMAC(t)
+		})
+	}
+}
+
+func TestNHGeneric(t *testing.T) {
+	for _, impl := range nhImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			defer impl.use()()
+			testNHGeneric(t)
+		})
+	}
+}
+
+func testNHGeneric(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var key [nhKeyLen / 4]uint32
+	for i := range key {
+		key[i] = rnd.Uint32()
+	}
+	data := make([]byte, L1_KEY_LEN+3)
+	rnd.Read(data)
+
+	kernels := []struct {
+		streams        int
+		fast, portable func(k []uint32, d []byte, hp []uint64, dlen int)
+	}{
+		{STREAMS4, nhAux4, nhAux4Generic},
+		{STREAMS8, nhAux8, nhAux8Generic},
+		{STREAMS12, nhAux12, nhAux12Generic},
+		{STREAMS16, nhAux16, nhAux16Generic},
+	}
+	for _, kn := range kernels {
+		for n := 0; n <= L1_KEY_LEN; n += L1_PAD_BOUNDARY {
+			// the message is not always aligned
+			off := rnd.Intn(4)
+			got := make([]uint64, kn.streams)
+			want := make([]uint64, kn.streams)
+			kn.fast(key[:], data[off:], got, n)
+			kn.portable(key[:], data[off:], want, n)
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("%d streams, %d bytes: stream %d is %x, expected %x", kn.streams, n, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkNH(b *testing.B) {
+	var key [nhKeyLen / 4]uint32
+	data := make([]byte, L1_KEY_LEN)
+	for _, impl := range nhImpls {
+		for _, kn := range []struct {
+			name    string
+			streams int
+			f       func(k []uint32, d []byte, hp []uint64, dlen int)
+		}{{"64", STREAMS8, nhAux8}, {"128", STREAMS16, nhAux16}} {
+			b.Run(impl.name+"/"+kn.name+"_1K", func(b *testing.B) {
+				defer impl.use()()
+				hp := make([]uint64, kn.streams)
+				b.SetBytes(L1_KEY_LEN)
+				for i := 0; i < b.N; i++ {
+					kn.f(key[:], data, hp, L1_KEY_LEN)
+				}
+			})
+		}
+	}
+}
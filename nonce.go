@@ -0,0 +1,156 @@
+package umac
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNonceExhausted is returned when a NonceSequence has no unused nonce left.
+var ErrNonceExhausted = errors.New("umac: nonce sequence exhausted")
+
+// NonceStore persists the high-water mark of a NonceSequence.
+type NonceStore interface {
+	// Load returns the last saved mark, or 0 if there is none.
+	Load() (uint64, error)
+	// Save durably records mark, no nonce at or above it has been handed out yet.
+	Save(mark uint64) error
+}
+
+// NonceSequence hands out strictly increasing 64-bit nonces, each at most once.
+// It is safe for concurrent use.
+//
+// The last value, 2^64-1, is never handed out, the sequence is exhausted before it would wrap.
+// A persistent sequence reserves nonces in steps, saving the end of each step before
+// using it, so after a restart it continues above every nonce it may have handed out.
+type NonceSequence struct {
+	next  atomic.Uint64
+	limit atomic.Uint64
+
+	mu    sync.Mutex // serializes the saves
+	store NonceStore
+	step  uint64
+}
+
+// NewNonceSequence creates an in-memory sequence starting at start.
+func NewNonceSequence(start uint64) *NonceSequence {
+	s := &NonceSequence{}
+	s.next.Store(start)
+	s.limit.Store(1<<64 - 1)
+	return s
+}
+
+// NewPersistentNonceSequence creates a sequence continuing from the mark saved in store.
+// Every step nonces the new mark is saved, a larger step means fewer saves but more
+// nonces skipped on restart.
+func NewPersistentNonceSequence(store NonceStore, step uint64) (*NonceSequence, error) {
+	if step == 0 {
+		step = 1
+	}
+	mark, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	s := &NonceSequence{store: store, step: step}
+	s.next.Store(mark)
+	s.limit.Store(mark)
+	return s, nil
+}
+
+// Next returns the next nonce as an integer.
+// It fails with ErrNonceExhausted once the sequence would wrap, or with the error of the store.
+func (s *NonceSequence) Next() (uint64, error) {
+	for {
+		n := s.next.Load()
+		if n == 1<<64-1 {
+			return 0, ErrNonceExhausted
+		}
+		if n >= s.limit.Load() {
+			if err := s.reserve(n); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if s.next.CompareAndSwap(n, n+1) {
+			return n, nil
+		}
+	}
+}
+
+// NextNonce returns the next nonce in the 8-byte big-endian form taken by the hashers.
+func (s *NonceSequence) NextNonce() ([NonceSize]byte, error) {
+	var nonce [NonceSize]byte
+	n, err := s.Next()
+	if err != nil {
+		return nonce, err
+	}
+	binary.BigEndian.PutUint64(nonce[:], n)
+	return nonce, nil
+}
+
+// reserve saves a new mark above n, unless a concurrent caller already did.
+func (s *NonceSequence) reserve(n uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < s.limit.Load() {
+		return nil
+	}
+	mark := n + s.step
+	if mark < n {
+		mark = 1<<64 - 1
+	}
+	if err := s.store.Save(mark); err != nil {
+		return err
+	}
+	s.limit.Store(mark)
+	return nil
+}
+
+// SetNextNonce sets the nonce to the next one of s and returns it, so it can be sent along the message.
+func (h *Hash) SetNextNonce(s *NonceSequence) ([NonceSize]byte, error) {
+	nonce, err := s.NextNonce()
+	if err != nil {
+		return nonce, err
+	}
+	h.nonce = nonce
+	h.nonceSet = true
+	return nonce, nil
+}
+
+// sumNext appends the next nonce of s and the tag under it to b, then resets u like Sum.
+func sumNext(u umac, s *NonceSequence, b []byte) ([]byte, error) {
+	nonce, err := s.NextNonce()
+	if err != nil {
+		return b, err
+	}
+	ret, out := sliceForAppend(b, NonceSize+u.Size())
+	copy(out, nonce[:])
+	u.tag(nonce, out[NonceSize:])
+	u.Reset()
+	return ret, nil
+}
+
+// SumNext appends the next nonce of s and the tag of the data written so far to b.
+// Like Sum, it resets the state.
+func (u *UMAC4) SumNext(s *NonceSequence, b []byte) ([]byte, error) {
+	return sumNext(u, s, b)
+}
+
+// SumNext appends the next nonce of s and the tag of the data written so far to b.
+// Like Sum, it resets the state.
+func (u *UMAC8) SumNext(s *NonceSequence, b []byte) ([]byte, error) {
+	return sumNext(u, s, b)
+}
+
+// SumNext appends the next nonce of s and the tag of the data written so far to b.
+// Like Sum, it resets the state.
+func (u *UMAC12) SumNext(s *NonceSequence, b []byte) ([]byte, error) {
+	return sumNext(u, s, b)
+}
+
+// SumNext appends the next nonce of s and the tag of the data written so far to b.
+// Like Sum, it resets the state.
+func (u *UMAC16) SumNext(s *NonceSequence, b []byte) ([]byte, error) {
+	return sumNext(u, s, b)
+}
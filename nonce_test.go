@@ -0,0 +1,172 @@
+package umac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type memStore struct {
+	mark  uint64
+	saves int
+	err   error
+}
+
+func (m *memStore) Load() (uint64, error) { return m.mark, m.err }
+
+func (m *memStore) Save(mark uint64) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.mark = mark
+	m.saves++
+	return nil
+}
+
+func TestNonceSequence(t *testing.T) {
+	s := NewNonceSequence(5)
+	for i := uint64(5); i < 10; i++ {
+		if n, err := s.Next(); n != i || err != nil {
+			t.Fatalf("Next = %d, %v, expected %d", n, err, i)
+		}
+	}
+	if nonce, _ := s.NextNonce(); nonce != [8]byte{7: 10} {
+		t.Errorf("NextNonce = %x", nonce)
+	}
+
+	s = NewNonceSequence(1<<64 - 3)
+	s.Next()
+	if n, err := s.Next(); n != 1<<64-2 || err != nil {
+		t.Fatalf("Next = %x, %v", n, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Next(); err != ErrNonceExhausted {
+			t.Errorf("Next after the last nonce: err = %v", err)
+		}
+	}
+}
+
+func TestNonceSequenceConcurrent(t *testing.T) {
+	store := &memStore{}
+	s, err := NewPersistentNonceSequence(store, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const workers, each = 8, 1000
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[uint64]bool)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := uint64(0)
+			for i := 0; i < each; i++ {
+				n, err := s.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if i > 0 && n <= last {
+					t.Errorf("nonce %d after %d", n, last)
+				}
+				last = n
+				mu.Lock()
+				if seen[n] {
+					t.Errorf("nonce %d handed out twice", n)
+				}
+				seen[n] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*each {
+		t.Errorf("%d distinct nonces, expected %d", len(seen), workers*each)
+	}
+	if store.mark < workers*each || store.saves > (workers*each+6)/7 {
+		t.Errorf("mark %d after %d saves", store.mark, store.saves)
+	}
+}
+
+func TestNonceSequencePersist(t *testing.T) {
+	store := &memStore{}
+	s, _ := NewPersistentNonceSequence(store, 100)
+	var last uint64
+	for i := 0; i < 150; i++ {
+		last, _ = s.Next()
+	}
+	if store.saves != 2 || store.mark != 200 {
+		t.Errorf("%d saves, mark %d", store.saves, store.mark)
+	}
+
+	// a restart continues above every nonce handed out before
+	s, _ = NewPersistentNonceSequence(store, 100)
+	if n, err := s.Next(); n <= last || err != nil {
+		t.Errorf("Next after restart = %d, %v, last was %d", n, err, last)
+	}
+
+	fail := errors.New("disk full")
+	store.err = fail
+	for i := 0; i < 99; i++ {
+		if _, err := s.Next(); err != nil {
+			t.Fatalf("Next inside the reserved step: %v", err)
+		}
+	}
+	if _, err := s.Next(); err != fail {
+		t.Errorf("Next with a failing store: err = %v", err)
+	}
+	if _, err := NewPersistentNonceSequence(store, 100); err != fail {
+		t.Errorf("NewPersistentNonceSequence with a failing store: err = %v", err)
+	}
+
+	store = &memStore{mark: 1<<64 - 2}
+	s, _ = NewPersistentNonceSequence(store, 100)
+	if n, err := s.Next(); n != 1<<64-2 || err != nil || store.mark != 1<<64-1 {
+		t.Errorf("Next = %x, %v, mark %x", n, err, store.mark)
+	}
+	if _, err := s.Next(); err != ErrNonceExhausted {
+		t.Errorf("Next after the last nonce: err = %v", err)
+	}
+}
+
+func TestSumNext(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	msg := bytes.Repeat([]byte("abc"), 500)
+	type nexter interface {
+		verifier
+		SumNext(*NonceSequence, []byte) ([]byte, error)
+	}
+	for _, u := range []nexter{New4(key).(*UMAC4), New8(key).(*UMAC8), New12(key).(*UMAC12), New16(key).(*UMAC16)} {
+		s := NewNonceSequence(41)
+		u.Write(msg)
+		out, err := u.SumNext(s, []byte("x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1+NonceSize+u.Size() || out[0] != 'x' || binary.BigEndian.Uint64(out[1:]) != 41 {
+			t.Fatalf("UMAC-%d: SumNext = %x", u.Size()*8, out)
+		}
+		if !u.Verify(out[1:9], msg, out[9:]) {
+			t.Errorf("UMAC-%d: SumNext tag does not verify", u.Size()*8)
+		}
+	}
+
+	h, _ := NewHash(key, 8)
+	s := NewNonceSequence(1<<64 - 2)
+	nonce, err := h.SetNextNonce(s)
+	if err != nil || nonce != [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe} {
+		t.Fatalf("SetNextNonce = %x, %v", nonce, err)
+	}
+	h.Write(msg)
+	if !New8(key).(*UMAC8).Verify(nonce[:], msg, h.Sum(nil)) {
+		t.Error("Hash tag under SetNextNonce does not verify")
+	}
+	if _, err := h.SetNextNonce(s); err != ErrNonceExhausted {
+		t.Errorf("SetNextNonce on an exhausted sequence: err = %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package umac
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+)
+
+// MaxPrecompute is the largest number of nonces whose pads Precompute prepares at once.
+const MaxPrecompute = 4096
+
+// padEntry is a pad block in the precomputed cache, tagged with its masked nonce.
+type padEntry struct {
+	nonce uint64
+	valid bool
+	pad   [aes.BlockSize]byte
+}
+
+// pad returns the AES output block of nonce n, whose low shift bits only select a part of it.
+// One block is cached, so consecutive UMAC-32 and UMAC-64 nonces share it,
+// and after Precompute the pads of the prepared range are looked up by nonce prefix.
+func (c *pdfCtx) pad(n uint64, shift uint) []byte {
+	prefix := n >> shift << shift
+	if len(c.pads) != 0 {
+		e := &c.pads[(n>>shift)&uint64(len(c.pads)-1)]
+		if !e.valid || e.nonce != prefix {
+			if c.shared {
+				c.own()
+				e = &c.pads[(n>>shift)&uint64(len(c.pads)-1)]
+			}
+			c.encrypt(prefix, e.pad[:])
+			e.nonce, e.valid = prefix, true
+		}
+		return e.pad[:]
+	}
+	if prefix != c.last {
+		c.encrypt(prefix, c.cache[:])
+		c.last = prefix
+	}
+	return c.cache[:]
+}
+
+// share marks the pads as referenced by a clone, so neither side writes them in place.
+func (c *pdfCtx) share() {
+	if cap(c.pads) != 0 {
+		c.shared = true
+	}
+}
+
+// own gives c a private copy of shared pads before they are written.
+func (c *pdfCtx) own() {
+	c.pads = append([]padEntry(nil), c.pads...)
+	c.shared = false
+}
+
+func (c *pdfCtx) encrypt(prefix uint64, dst []byte) {
+	binary.BigEndian.PutUint64(c.in[:], prefix)
+	c.cip.Encrypt(dst, c.in[:])
+}
+
+// precompute encrypts the pads of count nonces starting at the 8-byte nonce start.
+func (c *pdfCtx) precompute(start []byte, count int, shift uint) error {
+	if len(start) != NonceSize {
+		return NonceSizeError(len(start))
+	}
+	if count < 1 || count > MaxPrecompute {
+		return PrecomputeSizeError(count)
+	}
+	first := binary.BigEndian.Uint64(start)
+	last := first + uint64(count-1)
+	if last < first {
+		last = 1<<64 - 1
+	}
+	blocks := int(last>>shift - first>>shift + 1)
+	size := 1
+	for size < blocks {
+		size <<= 1
+	}
+	if c.shared || cap(c.pads) < size {
+		// the entries are rewritten below, so a shared table is dropped rather than copied
+		c.pads = make([]padEntry, size)
+		c.shared = false
+	}
+	c.pads = c.pads[:size]
+	for b := first >> shift; ; b++ {
+		e := &c.pads[b&uint64(size-1)]
+		if prefix := b << shift; !e.valid || e.nonce != prefix {
+			c.encrypt(prefix, e.pad[:])
+			e.nonce, e.valid = prefix, true
+		}
+		if b == last>>shift {
+			break
+		}
+	}
+	return nil
+}
+
+// Precompute prepares the pads of count nonces starting at nonce, at most MaxPrecompute,
+// so the AES calls of the following Sum calls are taken off the critical path.
+// The pads are kept in a cache keyed by nonce prefix, where 4 consecutive nonces share one AES block.
+func (u *UMAC4) Precompute(nonce []byte, count int) error {
+	return u.pdf.precompute(nonce, count, 2)
+}
+
+// Precompute prepares the pads of count nonces starting at nonce, at most MaxPrecompute,
+// so the AES calls of the following Sum calls are taken off the critical path.
+// The pads are kept in a cache keyed by nonce prefix, where 2 consecutive nonces share one AES block.
+func (u *UMAC8) Precompute(nonce []byte, count int) error {
+	return u.pdf.precompute(nonce, count, 1)
+}
+
+// Precompute prepares the pads of count nonces starting at nonce, at most MaxPrecompute,
+// so the AES calls of the following Sum calls are taken off the critical path.
+// The pads are kept in a cache keyed by nonce.
+func (u *UMAC12) Precompute(nonce []byte, count int) error {
+	return u.pdf.precompute(nonce, count, 0)
+}
+
+// Precompute prepares the pads of count nonces starting at nonce, at most MaxPrecompute,
+// so the AES calls of the following Sum calls are taken off the critical path.
+// The pads are kept in a cache keyed by nonce.
+func (u *UMAC16) Precompute(nonce []byte, count int) error {
+	return u.pdf.precompute(nonce, count, 0)
+}
+
+// Precompute prepares the pads of count nonces starting at nonce, see UMAC8.Precompute.
+func (h *Hash) Precompute(nonce []byte, count int) error {
+	return h.mac.Precompute(nonce, count)
+}
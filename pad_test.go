@@ -0,0 +1,145 @@
+package umac
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"testing"
+)
+
+// countingBlock counts the AES calls of the pad generation.
+type countingBlock struct {
+	cipher.Block
+	n int
+}
+
+func (c *countingBlock) Encrypt(dst, src []byte) {
+	c.n++
+	c.Block.Encrypt(dst, src)
+}
+
+type precomputer interface {
+	verifier
+	Precompute(nonce []byte, count int) error
+}
+
+func TestPadCache(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	k, _ := NewKey(key)
+	msg := []byte("some message")
+	refs := []macer{k.Key4(), k.Key8(), k.Key12(), k.Key16()}
+	// AES calls for 64 sequential nonces
+	calls := []int{16, 32, 64, 64}
+
+	for i, newU := range []func() (precomputer, *pdfCtx){
+		func() (precomputer, *pdfCtx) { u := k.New4(); return u, &u.pdf },
+		func() (precomputer, *pdfCtx) { u := k.New8(); return u, &u.pdf },
+		func() (precomputer, *pdfCtx) { u := k.New12(); return u, &u.pdf },
+		func() (precomputer, *pdfCtx) { u := k.New16(); return u, &u.pdf },
+	} {
+		for _, start := range []uint64{0, 1, 5, 1<<32 - 3, 1<<64 - 40} {
+			for _, precompute := range []bool{false, true} {
+				u, pdf := newU()
+				cb := &countingBlock{Block: pdf.cip}
+				pdf.cip = cb
+				nonce := make([]byte, 8)
+				binary.BigEndian.PutUint64(nonce, start)
+				if precompute {
+					if err := u.Precompute(nonce, 64); err != nil {
+						t.Fatal(err)
+					}
+					cb.n = 0
+				}
+				for j := uint64(0); j < 64 && start+j >= start; j++ {
+					binary.BigEndian.PutUint64(nonce, start+j)
+					u.Write(msg)
+					tag := u.Sum(bytes.Clone(nonce))
+					if want := refs[i].MAC(nil, nonce, msg); !bytes.Equal(tag, want) {
+						t.Fatalf("UMAC-%d: nonce %x: tag %x, expected %x", u.Size()*8, start+j, tag, want)
+					}
+				}
+				aligned := start%uint64(64/calls[i]) == 0 && start < 1<<63
+				switch {
+				case precompute && cb.n != 0:
+					t.Errorf("UMAC-%d: start %x: %d AES calls after Precompute", u.Size()*8, start, cb.n)
+				case !precompute && aligned && start != 0 && cb.n != calls[i]:
+					t.Errorf("UMAC-%d: start %x: %d AES calls, expected %d", u.Size()*8, start, cb.n, calls[i])
+				}
+			}
+		}
+	}
+}
+
+func TestPrecomputeMisses(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	k, _ := NewKey(key)
+	u := k.New8()
+	nonce := []byte{0, 0, 0, 0, 0, 0, 1, 0}
+	if err := u.Precompute(nonce, 10); err != nil {
+		t.Fatal(err)
+	}
+	// nonces outside the range replace cache entries and stay correct
+	for _, n := range []uint64{0x100, 0x300, 0x101, 0x7, 0x100, 1<<64 - 1} {
+		binary.BigEndian.PutUint64(nonce, n)
+		u.Write(nonce)
+		tag := u.Sum(bytes.Clone(nonce))
+		if want := k.Key8().MAC(nil, nonce, nonce); !bytes.Equal(tag, want) {
+			t.Errorf("nonce %x: tag %x, expected %x", n, tag, want)
+		}
+	}
+
+	for _, count := range []int{0, -1, MaxPrecompute + 1} {
+		if err := u.Precompute(nonce, count); err != PrecomputeSizeError(count) {
+			t.Errorf("Precompute(%d): err = %v", count, err)
+		}
+	}
+	if err := u.Precompute(nonce[:4], 1); err != NonceSizeError(4) {
+		t.Errorf("Precompute with a 4-byte nonce: err = %v", err)
+	}
+	h, _ := k.NewHash(16)
+	if err := h.Precompute(nonce, MaxPrecompute); err != nil {
+		t.Errorf("Hash.Precompute: %v", err)
+	}
+}
+
+// BenchmarkPadLatency measures Sum over sequential nonces, with the pads encrypted
+// on the way or all precomputed before the timer starts.
+// The nonces cycle through MaxPrecompute values, so one Precompute covers the whole run.
+//
+//	go test -run - -bench PadLatency -count 5 [-tags purego]
+func BenchmarkPadLatency(b *testing.B) {
+	msg := make([]byte, 64)
+	for _, bc := range []struct {
+		name string
+		u    precomputer
+	}{
+		{"64", New8(make([]byte, 16)).(*UMAC8)},
+		{"128", New16(make([]byte, 16)).(*UMAC16)},
+	} {
+		for _, precompute := range []bool{false, true} {
+			name := bc.name + "_inline"
+			if precompute {
+				name = bc.name + "_precomputed"
+			}
+			b.Run(name, func(b *testing.B) {
+				u := bc.u
+				nonce := make([]byte, 8)
+				buf := make([]byte, 16)
+				u.Reset()
+				if precompute {
+					if err := u.Precompute(nonce, MaxPrecompute); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.SetBytes(int64(len(msg)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					binary.BigEndian.PutUint64(nonce, uint64(i%MaxPrecompute))
+					u.Write(msg)
+					copy(buf, nonce)
+					u.Sum(buf[:8])
+				}
+			})
+		}
+	}
+}
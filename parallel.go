@@ -0,0 +1,236 @@
+package umac
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parallelSegment is the number of L1 blocks hashed by a worker at once.
+const parallelSegment = 256
+
+// readFull reads exactly len(buf) bytes at off, a short read is io.ErrUnexpectedEOF.
+func readFull(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// parallelNH runs nh over every L1 block of the first size bytes of r on several workers,
+// and hands the results to poly in message order, a segment of blocks at a time.
+func parallelNH(ctx context.Context, r io.ReaderAt, size int64, workers, streams int,
+	nh func(block []byte, result []uint64), poly func(results []uint64)) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	const segBytes = parallelSegment * L1_KEY_LEN
+	nseg := (size + segBytes - 1) / segBytes
+	// segment i is computed in slot i%inflight, so at most inflight result buffers are alive
+	inflight := 2 * workers
+	if int64(inflight) > nseg {
+		inflight = int(nseg)
+	}
+
+	type segment struct {
+		results []uint64
+		err     error
+	}
+	slots := make([]chan segment, inflight)
+	bufs := make([][]uint64, inflight)
+	for i := range slots {
+		slots[i] = make(chan segment, 1)
+		bufs[i] = make([]uint64, parallelSegment*streams)
+	}
+	jobs := make(chan int64, inflight)
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		close(jobs)
+		cancel()
+		wg.Wait()
+	}()
+	for w := 0; w < workers && w < inflight; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, segBytes)
+			for i := range jobs {
+				slot := i % int64(inflight)
+				if err := ctx.Err(); err != nil {
+					slots[slot] <- segment{err: err}
+					continue
+				}
+				off := i * segBytes
+				n := size - off
+				if n > segBytes {
+					n = segBytes
+				}
+				if err := readFull(r, data[:n], off); err != nil {
+					slots[slot] <- segment{err: err}
+					continue
+				}
+				res := bufs[slot]
+				k := 0
+				for b := 0; b < int(n); b += L1_KEY_LEN {
+					end := b + L1_KEY_LEN
+					if end > int(n) {
+						end = int(n)
+					}
+					nh(data[b:end], res[k:k+streams])
+					k += streams
+				}
+				slots[slot] <- segment{results: res[:k]}
+			}
+		}()
+	}
+
+	for i := int64(0); i < int64(inflight); i++ {
+		jobs <- i
+	}
+	for i := int64(0); i < nseg; i++ {
+		var seg segment
+		select {
+		case seg = <-slots[i%int64(inflight)]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if seg.err != nil {
+			return seg.err
+		}
+		poly(seg.results)
+		if next := i + int64(inflight); next < nseg {
+			jobs <- next
+		}
+	}
+	return nil
+}
+
+// sumParallelShort handles messages of at most one L1 block, which are not worth splitting.
+func sumParallelShort(r io.ReaderAt, size int64, nonce []byte, mac func(dst, nonce, msg []byte) []byte) ([]byte, error) {
+	if size < 0 {
+		return nil, ErrNegativeSize
+	}
+	msg := make([]byte, size)
+	if err := readFull(r, msg, 0); err != nil {
+		return nil, err
+	}
+	return mac(nil, nonce, msg), nil
+}
+
+// SumParallel computes the UMAC-32 tag of the first size bytes of r under the 8-byte nonce,
+// running the NH layer on workers goroutines, or GOMAXPROCS if workers is not positive.
+// The tag is the same as the one of MAC, only the polynomial layer is sequential.
+// Reading stops early and the error of ctx is returned if it is done before the end.
+func (k *Key4) SumParallel(ctx context.Context, r io.ReaderAt, size int64, nonce []byte, workers int) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+	if size <= L1_KEY_LEN {
+		return sumParallelShort(r, size, nonce, k.MAC)
+	}
+	var h uhash4
+	h.init(k.key)
+	err := parallelNH(ctx, r, size, workers, STREAMS4, h.nh.hashShort, func(res []uint64) {
+		for i := 0; i < len(res); i += STREAMS4 {
+			h.polyHash(res[i : i+STREAMS4])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out [4]byte
+	h.ipLong(out[:])
+	return padTag(k.key, nonce, 0x03, out[:]), nil
+}
+
+// SumParallel computes the UMAC-64 tag of the first size bytes of r under the 8-byte nonce,
+// running the NH layer on workers goroutines, or GOMAXPROCS if workers is not positive.
+// The tag is the same as the one of MAC, only the polynomial layer is sequential.
+// Reading stops early and the error of ctx is returned if it is done before the end.
+func (k *Key8) SumParallel(ctx context.Context, r io.ReaderAt, size int64, nonce []byte, workers int) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+	if size <= L1_KEY_LEN {
+		return sumParallelShort(r, size, nonce, k.MAC)
+	}
+	var h uhash8
+	h.init(k.key)
+	err := parallelNH(ctx, r, size, workers, STREAMS8, h.nh.hashShort, func(res []uint64) {
+		for i := 0; i < len(res); i += STREAMS8 {
+			h.polyHash(res[i : i+STREAMS8])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out [8]byte
+	h.ipLong(out[:])
+	return padTag(k.key, nonce, 0x01, out[:]), nil
+}
+
+// SumParallel computes the UMAC-96 tag of the first size bytes of r under the 8-byte nonce,
+// running the NH layer on workers goroutines, or GOMAXPROCS if workers is not positive.
+// The tag is the same as the one of MAC, only the polynomial layer is sequential.
+// Reading stops early and the error of ctx is returned if it is done before the end.
+func (k *Key12) SumParallel(ctx context.Context, r io.ReaderAt, size int64, nonce []byte, workers int) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+	if size <= L1_KEY_LEN {
+		return sumParallelShort(r, size, nonce, k.MAC)
+	}
+	var h uhash12
+	h.init(k.key)
+	err := parallelNH(ctx, r, size, workers, STREAMS12, h.nh.hashShort, func(res []uint64) {
+		for i := 0; i < len(res); i += STREAMS12 {
+			h.polyHash(res[i : i+STREAMS12])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out [12]byte
+	h.ipLong(out[:])
+	return padTag(k.key, nonce, 0, out[:]), nil
+}
+
+// SumParallel computes the UMAC-128 tag of the first size bytes of r under the 8-byte nonce,
+// running the NH layer on workers goroutines, or GOMAXPROCS if workers is not positive.
+// The tag is the same as the one of MAC, only the polynomial layer is sequential.
+// Reading stops early and the error of ctx is returned if it is done before the end.
+func (k *Key16) SumParallel(ctx context.Context, r io.ReaderAt, size int64, nonce []byte, workers int) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+	if size <= L1_KEY_LEN {
+		return sumParallelShort(r, size, nonce, k.MAC)
+	}
+	var h uhash16
+	h.init(k.key)
+	err := parallelNH(ctx, r, size, workers, STREAMS16, h.nh.hashShort, func(res []uint64) {
+		for i := 0; i < len(res); i += STREAMS16 {
+			h.polyHash(res[i : i+STREAMS16])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out [16]byte
+	h.ipLong(out[:])
+	return padTag(k.key, nonce, 0, out[:]), nil
+}
+
+// padTag returns the hash output xored with the pad of nonce,
+// mask selects the low bits of the nonce used as index into the pad block.
+func padTag(k *Key, nonce []byte, mask byte, out []byte) []byte {
+	k.xorPad([NonceSize]byte(nonce), mask, out)
+	return append([]byte(nil), out...)
+}
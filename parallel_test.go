@@ -0,0 +1,98 @@
+package umac
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+type parallelMacer interface {
+	macer
+	SumParallel(ctx context.Context, r io.ReaderAt, size int64, nonce []byte, workers int) ([]byte, error)
+}
+
+// failingReader fails reads past off.
+type failingReader struct {
+	io.ReaderAt
+	off int64
+}
+
+var errRead = errors.New("read failed")
+
+func (f failingReader) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > f.off {
+		return 0, errRead
+	}
+	return f.ReaderAt.ReadAt(p, off)
+}
+
+func TestSumParallel(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 3*parallelSegment*L1_KEY_LEN+777)
+	rnd.Read(data)
+	r := bytes.NewReader(data)
+	nonce := []byte("bcdefghi")
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+
+	sizes := []int{0, 1, 1024, 1025, 2048, 2049, parallelSegment * L1_KEY_LEN, parallelSegment*L1_KEY_LEN + 1, len(data)}
+	for _, m := range []parallelMacer{k.Key4(), k.Key8(), k.Key12(), k.Key16()} {
+		for _, size := range sizes {
+			want := m.MAC(nil, nonce, data[:size])
+			for _, workers := range []int{0, 1, 2, 7} {
+				tag, err := m.SumParallel(context.Background(), r, int64(size), nonce, workers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(tag, want) {
+					t.Errorf("size %d, %d workers: tag %x, expected %x", size, workers, tag, want)
+				}
+			}
+		}
+	}
+
+	m := k.Key8()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.SumParallel(ctx, r, int64(len(data)), nonce, 2); err != context.Canceled {
+		t.Errorf("canceled context: err = %v", err)
+	}
+	if _, err := m.SumParallel(context.Background(), failingReader{r, 300000}, int64(len(data)), nonce, 2); err != errRead {
+		t.Errorf("failing reader: err = %v", err)
+	}
+	for _, size := range []int64{100, int64(len(data))} {
+		if _, err := m.SumParallel(context.Background(), bytes.NewReader(data[:size-1]), size, nonce, 2); err != io.ErrUnexpectedEOF {
+			t.Errorf("short reader of %d bytes: err = %v", size-1, err)
+		}
+	}
+	if _, err := m.SumParallel(context.Background(), r, -1, nonce, 2); err != ErrNegativeSize {
+		t.Errorf("negative size: err = %v", err)
+	}
+	if _, err := m.SumParallel(context.Background(), r, 10, nonce[:7], 2); err != NonceSizeError(7) {
+		t.Errorf("7-byte nonce: err = %v", err)
+	}
+}
+
+func BenchmarkSumParallel(b *testing.B) {
+	data := make([]byte, 64<<20)
+	r := bytes.NewReader(data)
+	k, _ := NewKey8(make([]byte, 16))
+	nonce := make([]byte, 8)
+	b.Run("sequential", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			k.MAC(nil, nonce, data)
+		}
+	})
+	for _, workers := range []int{1, 2, 4} {
+		b.Run(strconv.Itoa(workers)+"_workers", func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				k.SumParallel(context.Background(), r, int64(len(data)), nonce, workers)
+			}
+		})
+	}
+}
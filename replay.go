@@ -0,0 +1,89 @@
+package umac
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxReplayWindow is the largest window accepted by NewReplayWindow.
+const MaxReplayWindow = 8192
+
+// ReplayWindow rejects received nonces that were seen before or are too old,
+// like the anti-replay window of IPsec.
+// Check a nonce only after its tag has been verified, or a forged message can shift the window.
+// A ReplayWindow is safe for concurrent use, receivers on different cores only
+// contend when their nonces fall into the same 64-nonce block.
+type ReplayWindow struct {
+	size  uint64
+	top   atomic.Uint64 // highest nonce accepted
+	slots []replaySlot
+}
+
+// replaySlot holds the bitmap of one block of 64 nonces, the slots are used as a ring.
+type replaySlot struct {
+	mu    sync.Mutex
+	block uint64
+	bits  uint64
+	_     [40]byte // keep slots on separate cache lines
+}
+
+// NewReplayWindow creates a window accepting nonces up to size below the highest one seen,
+// size is rounded up to a multiple of 64 and must not be larger than MaxReplayWindow.
+func NewReplayWindow(size int) (*ReplayWindow, error) {
+	if size < 1 || size > MaxReplayWindow {
+		return nil, WindowSizeError(size)
+	}
+	blocks := (size + 63) / 64
+	return &ReplayWindow{
+		size: uint64(blocks) * 64,
+		// one more block, so the whole window is covered when it does not start at a block boundary
+		slots: make([]replaySlot, blocks+1),
+	}, nil
+}
+
+// Size returns the window size, the number of nonces below the highest one seen that are still accepted.
+func (w *ReplayWindow) Size() int {
+	return int(w.size)
+}
+
+// Check reports whether the 8-byte big-endian nonce is new and inside the window, and records it.
+func (w *ReplayWindow) Check(nonce []byte) bool {
+	if len(nonce) != NonceSize {
+		return false
+	}
+	return w.CheckUint64(binary.BigEndian.Uint64(nonce))
+}
+
+// CheckUint64 reports whether the nonce n is new and inside the window, and records it.
+func (w *ReplayWindow) CheckUint64(n uint64) bool {
+	top := w.top.Load()
+	if top >= w.size && n <= top-w.size {
+		return false
+	}
+
+	block := n / 64
+	s := &w.slots[block%uint64(len(w.slots))]
+	s.mu.Lock()
+	if s.block > block {
+		// the slot was taken by a block at least a window ahead
+		s.mu.Unlock()
+		return false
+	}
+	if s.block < block {
+		s.block = block
+		s.bits = 0
+	}
+	bit := uint64(1) << (n % 64)
+	seen := s.bits&bit != 0
+	s.bits |= bit
+	s.mu.Unlock()
+	if seen {
+		return false
+	}
+
+	for top < n && !w.top.CompareAndSwap(top, n) {
+		top = w.top.Load()
+	}
+	return true
+}
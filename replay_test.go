@@ -0,0 +1,124 @@
+package umac
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// replayModel is the reference window, a set of all nonces seen and the highest one.
+type replayModel struct {
+	size uint64
+	top  uint64
+	seen map[uint64]bool
+}
+
+func (m *replayModel) check(n uint64) bool {
+	if m.top >= m.size && n <= m.top-m.size || m.seen[n] {
+		return false
+	}
+	m.seen[n] = true
+	if n > m.top {
+		m.top = n
+	}
+	return true
+}
+
+func TestReplayWindow(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, size := range []int{1, 64, 100, 1000, MaxReplayWindow} {
+		w, err := NewReplayWindow(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &replayModel{size: uint64(w.Size()), seen: make(map[uint64]bool)}
+		base := uint64(0)
+		back := func(d int) uint64 {
+			if uint64(d) > base {
+				return 0
+			}
+			return base - uint64(d)
+		}
+		for i := 0; i < 50000; i++ {
+			// mostly moving forward, with shuffled, duplicated and very old nonces mixed in
+			var n uint64
+			switch r := rnd.Intn(10); {
+			case r < 5:
+				base += uint64(rnd.Intn(3 * w.Size() / 2))
+				n = base
+			case r < 8:
+				n = back(rnd.Intn(w.Size() + 64))
+			case r < 9:
+				n = back(rnd.Intn(64))
+			default:
+				n = uint64(rnd.Int63n(int64(base + 1)))
+			}
+			if got, want := w.CheckUint64(n), m.check(n); got != want {
+				t.Fatalf("size %d: CheckUint64(%d) = %v, expected %v, top %d", size, n, got, want, m.top)
+			}
+		}
+	}
+}
+
+func TestReplayWindowEdges(t *testing.T) {
+	w, _ := NewReplayWindow(100)
+	if w.Size() != 128 {
+		t.Errorf("Size = %d, expected 128", w.Size())
+	}
+	var nonce [8]byte
+	binary.BigEndian.PutUint64(nonce[:], 1<<64-1)
+	if !w.Check(nonce[:]) || w.Check(nonce[:]) {
+		t.Error("the last nonce was not accepted exactly once")
+	}
+	if !w.CheckUint64(1<<64-128) || w.CheckUint64(1<<64-129) {
+		t.Error("window boundary below the last nonce")
+	}
+	if w.Check(nonce[:7]) {
+		t.Error("a 7-byte nonce was accepted")
+	}
+	for _, size := range []int{0, -1, MaxReplayWindow + 1} {
+		if _, err := NewReplayWindow(size); err != WindowSizeError(size) {
+			t.Errorf("NewReplayWindow(%d): err = %v", size, err)
+		}
+	}
+}
+
+func TestReplayWindowConcurrent(t *testing.T) {
+	w, _ := NewReplayWindow(4096)
+	nonces := rand.New(rand.NewSource(2)).Perm(4096)
+	var (
+		wg       sync.WaitGroup
+		accepted [4096]atomic.Int32
+	)
+	// every nonce is delivered by 4 receivers, only one may accept it
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := range nonces {
+				n := nonces[(i+r*1024)%len(nonces)]
+				if w.CheckUint64(uint64(n)) {
+					accepted[n].Add(1)
+				}
+			}
+		}(r)
+	}
+	wg.Wait()
+	for n := range accepted {
+		if c := accepted[n].Load(); c != 1 {
+			t.Fatalf("nonce %d accepted %d times", n, c)
+		}
+	}
+}
+
+func BenchmarkReplayWindow(b *testing.B) {
+	w, _ := NewReplayWindow(2048)
+	var next atomic.Uint64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.CheckUint64(next.Add(1))
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package sshmac
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrMACMismatch is returned by Opener.Open when the tag of a packet is wrong.
+var ErrMACMismatch = errors.New("sshmac: MAC mismatch")
+
+// ErrPadding is returned by Opener.Open when the padding_length field of a packet is invalid.
+var ErrPadding = errors.New("sshmac: invalid padding length")
+
+// UnknownAlgorithmError is returned when the algorithm name is not supported.
+type UnknownAlgorithmError string
+
+func (e UnknownAlgorithmError) Error() string {
+	return "sshmac: unknown MAC algorithm " + string(e)
+}
+
+// PacketLengthError is returned when the packet_length field of a packet is out of range
+// or not a multiple of the cipher block size.
+type PacketLengthError int
+
+func (e PacketLengthError) Error() string {
+	return "sshmac: invalid packet length " + strconv.Itoa(int(e))
+}
@@ -0,0 +1,121 @@
+package sshmac
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// MaxPacketSize is the largest packet_length accepted, the limit of OpenSSH.
+	MaxPacketSize = 256 * 1024
+
+	minPadding = 4
+	minBlock   = 8
+)
+
+// Sealer encrypts packets in the encrypt-then-MAC mode.
+// A Sealer holds the cipher state of one direction of a connection and must not be used concurrently.
+type Sealer struct {
+	mac       *MAC
+	stream    cipher.Stream
+	blockSize int
+	rand      io.Reader
+}
+
+// NewSealer creates a Sealer encrypting with stream and authenticating with m.
+// The packets are padded to a multiple of blockSize, the block size of the negotiated cipher,
+// or 8 if it is smaller.
+func NewSealer(m *MAC, stream cipher.Stream, blockSize int) *Sealer {
+	return &Sealer{mac: m, stream: stream, blockSize: alignment(blockSize), rand: rand.Reader}
+}
+
+// Seal appends the packet of payload with sequence number seq to dst and returns the result.
+// The packet is the packet_length field, the encrypted padding_length, payload and random padding,
+// and the tag over all of it.
+func (s *Sealer) Seal(dst []byte, seq uint32, payload []byte) ([]byte, error) {
+	padding := s.blockSize - (1+len(payload))%s.blockSize
+	if padding < minPadding {
+		padding += s.blockSize
+	}
+	length := 1 + len(payload) + padding
+	if length > MaxPacketSize {
+		return nil, PacketLengthError(length)
+	}
+
+	start := len(dst)
+	ret := append(dst, make([]byte, 4+length)...)
+	out := ret[start:]
+	binary.BigEndian.PutUint32(out, uint32(length))
+	out[4] = byte(padding)
+	copy(out[5:], payload)
+	if _, err := io.ReadFull(s.rand, out[5+len(payload):]); err != nil {
+		return nil, err
+	}
+	s.stream.XORKeyStream(out[4:], out[4:])
+	return s.mac.Compute(ret, seq, ret[start:]), nil
+}
+
+// Opener checks and decrypts packets in the encrypt-then-MAC mode.
+// An Opener holds the cipher state of one direction of a connection and must not be used concurrently.
+type Opener struct {
+	mac       *MAC
+	stream    cipher.Stream
+	blockSize int
+}
+
+// NewOpener creates an Opener decrypting with stream and authenticating with m,
+// blockSize is the same as for NewSealer.
+func NewOpener(m *MAC, stream cipher.Stream, blockSize int) *Opener {
+	return &Opener{mac: m, stream: stream, blockSize: alignment(blockSize)}
+}
+
+// PacketLength returns the size of the whole packet, including the packet_length field and the tag,
+// from its first 4 bytes, so that a transport knows how much to read before calling Open.
+func (o *Opener) PacketLength(header []byte) (int, error) {
+	if len(header) < 4 {
+		return 0, PacketLengthError(len(header))
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < uint32(o.blockSize) || length > MaxPacketSize || length%uint32(o.blockSize) != 0 {
+		return 0, PacketLengthError(length)
+	}
+	return 4 + int(length) + o.mac.Size(), nil
+}
+
+// Open checks the tag of the packet with sequence number seq, then decrypts it,
+// and appends its payload to dst.
+// The packet is the whole packet as returned by Seal, the ciphertext is only decrypted
+// if the tag is valid, so the cipher state is unchanged on ErrMACMismatch.
+func (o *Opener) Open(dst []byte, seq uint32, packet []byte) ([]byte, error) {
+	n, err := o.PacketLength(packet)
+	if err != nil {
+		return nil, err
+	}
+	if len(packet) != n {
+		return nil, PacketLengthError(len(packet) - 4 - o.mac.Size())
+	}
+	body := n - o.mac.Size()
+	if !o.mac.Verify(seq, packet[:body], packet[body:]) {
+		return nil, ErrMACMismatch
+	}
+
+	start := len(dst)
+	ret := append(dst, make([]byte, body-4)...)
+	out := ret[start:]
+	o.stream.XORKeyStream(out, packet[4:body])
+	padding := int(out[0])
+	if padding < minPadding || padding > len(out)-1 {
+		return nil, ErrPadding
+	}
+	copy(out, out[1:len(out)-padding])
+	return ret[:len(ret)-padding-1], nil
+}
+
+func alignment(blockSize int) int {
+	if blockSize < minBlock {
+		return minBlock
+	}
+	return blockSize
+}
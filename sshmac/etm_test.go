@@ -0,0 +1,212 @@
+package sshmac
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"testing"
+
+	"github.com/fakeboboliu/umac"
+)
+
+// countingStream records how many bytes went through the wrapped stream.
+type countingStream struct {
+	cipher.Stream
+	n int
+}
+
+func (c *countingStream) XORKeyStream(dst, src []byte) {
+	c.n += len(src)
+	c.Stream.XORKeyStream(dst, src)
+}
+
+// patternReader fills the padding with a known pattern so the transcripts are reproducible.
+type patternReader byte
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(*p)
+		*p++
+	}
+	return len(b), nil
+}
+
+func newCTR(t testing.TB) cipher.Stream {
+	block, err := aes.NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher.NewCTR(block, make([]byte, aes.BlockSize))
+}
+
+func TestETMTranscript(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	payloads := [][]byte{{}, {21}, []byte("hello"), bytes.Repeat([]byte{'a'}, 10), bytes.Repeat([]byte{'b'}, 11), bytes.Repeat([]byte{'c'}, 2000)}
+
+	for _, name := range []string{UMAC64ETM, UMAC128ETM} {
+		m, err := New(name, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.ETM() {
+			t.Errorf("%s: ETM() = false", name)
+		}
+		s := NewSealer(m, newCTR(t), aes.BlockSize)
+		var pattern patternReader
+		s.rand = &pattern
+
+		// the transcript is built independently, following the OpenSSH packet format
+		var transcript, want []byte
+		ref := newCTR(t)
+		var refPattern patternReader
+		for i, p := range payloads {
+			seq := uint32(1<<32-3) + uint32(i)
+			packet, err := s.Seal(nil, seq, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transcript = append(transcript, packet...)
+
+			padding := 16 - (1+len(p))%16
+			if padding < 4 {
+				padding += 16
+			}
+			plain := make([]byte, 4+1+len(p)+padding)
+			binary.BigEndian.PutUint32(plain, uint32(len(plain)-4))
+			plain[4] = byte(padding)
+			copy(plain[5:], p)
+			refPattern.Read(plain[5+len(p):])
+			ref.XORKeyStream(plain[4:], plain[4:])
+			var h hash.Hash
+			if m.Size() == 8 {
+				h = umac.New8(key)
+			} else {
+				h = umac.New16(key)
+			}
+			h.Write(plain)
+			nonce := make([]byte, 8)
+			binary.BigEndian.PutUint64(nonce, uint64(seq))
+			want = append(append(want, plain...), h.Sum(nonce)...)
+		}
+		if !bytes.Equal(transcript, want) {
+			t.Fatalf("%s: transcript mismatch\n got %x\nwant %x", name, transcript, want)
+		}
+
+		o := NewOpener(m, newCTR(t), aes.BlockSize)
+		for i, p := range payloads {
+			seq := uint32(1<<32-3) + uint32(i)
+			n, err := o.PacketLength(transcript)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := o.Open([]byte("x"), seq, transcript[:n])
+			if err != nil {
+				t.Fatalf("%s: packet %d: %v", name, i, err)
+			}
+			if !bytes.Equal(got, append([]byte("x"), p...)) {
+				t.Errorf("%s: packet %d: Open = %x, expected %x", name, i, got, p)
+			}
+			transcript = transcript[n:]
+		}
+		if len(transcript) != 0 {
+			t.Errorf("%s: %d bytes left over", name, len(transcript))
+		}
+	}
+}
+
+func TestETMRejects(t *testing.T) {
+	m, _ := New(UMAC64ETM, []byte("abcdefghijklmnop"))
+	s := NewSealer(m, newCTR(t), 8)
+	packet, err := s.Seal(nil, 5, []byte("some payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(packet)-4-8)%8 != 0 {
+		t.Fatalf("packet length %d is not aligned", len(packet))
+	}
+
+	for i, tc := range []struct {
+		seq    uint32
+		packet func() []byte
+		err    error
+	}{
+		{6, func() []byte { return packet }, ErrMACMismatch},
+		{5, func() []byte { p := bytes.Clone(packet); p[10] ^= 1; return p }, ErrMACMismatch},
+		{5, func() []byte { p := bytes.Clone(packet); p[len(p)-1] ^= 1; return p }, ErrMACMismatch},
+		{5, func() []byte { return packet[:len(packet)-1] }, PacketLengthError(0)},
+		{5, func() []byte { return append(bytes.Clone(packet), 0) }, PacketLengthError(0)},
+		{5, func() []byte { p := bytes.Clone(packet); p[3] ^= 8; return p }, PacketLengthError(0)},
+		{5, func() []byte { p := bytes.Clone(packet); p[3]++; return p }, PacketLengthError(0)},
+	} {
+		stream := &countingStream{Stream: newCTR(t)}
+		o := NewOpener(m, stream, 8)
+		_, err := o.Open(nil, tc.seq, tc.packet())
+		var lerr PacketLengthError
+		if tc.err == ErrMACMismatch && err != ErrMACMismatch || tc.err != ErrMACMismatch && !errors.As(err, &lerr) {
+			t.Errorf("case %d: err = %v, expected %T", i, err, tc.err)
+		}
+		if stream.n != 0 {
+			t.Errorf("case %d: %d bytes decrypted before the tag was checked", i, stream.n)
+		}
+	}
+
+	// a valid tag over a bad padding_length
+	body := make([]byte, 4+16)
+	binary.BigEndian.PutUint32(body, 16)
+	body[4] = 2
+	newCTR(t).XORKeyStream(body[4:], body[4:])
+	o := NewOpener(m, newCTR(t), 8)
+	if _, err := o.Open(nil, 0, m.Compute(body, 0, body)); err != ErrPadding {
+		t.Errorf("padding_length 2: err = %v, expected ErrPadding", err)
+	}
+
+	if _, err := s.Seal(nil, 0, make([]byte, MaxPacketSize)); !errors.As(err, new(PacketLengthError)) {
+		t.Errorf("Seal of an oversized payload: err = %v, expected PacketLengthError", err)
+	}
+	if _, err := o.PacketLength([]byte{0, 0, 0}); err == nil {
+		t.Error("PacketLength accepted a short header")
+	}
+}
+
+func TestETMNoAlloc(t *testing.T) {
+	m, _ := New(UMAC64ETM, make([]byte, KeySize))
+	s := NewSealer(m, newCTR(t), aes.BlockSize)
+	var pattern patternReader
+	s.rand = &pattern
+	payload := make([]byte, 100)
+	dst := make([]byte, 0, 256)
+	packet, _ := s.Seal(nil, 0, payload)
+	o := NewOpener(m, newCTR(t), aes.BlockSize)
+	if n := testing.AllocsPerRun(100, func() {
+		s.Seal(dst, 0, payload)
+		o.Open(dst, 0, packet)
+	}); n != 0 {
+		t.Errorf("Seal and Open allocated %v times", n)
+	}
+}
+
+func BenchmarkSeal(b *testing.B) {
+	m, _ := New(UMAC64ETM, make([]byte, KeySize))
+	s := NewSealer(m, newCTR(b), aes.BlockSize)
+	payload := make([]byte, 1024)
+	dst := make([]byte, 0, 2048)
+	b.SetBytes(int64(len(payload)))
+	for i := 0; i < b.N; i++ {
+		s.Seal(dst, uint32(i), payload)
+	}
+}
+
+func BenchmarkOpen(b *testing.B) {
+	m, _ := New(UMAC64ETM, make([]byte, KeySize))
+	packet, _ := NewSealer(m, newCTR(b), aes.BlockSize).Seal(nil, 0, make([]byte, 1024))
+	o := NewOpener(m, newCTR(b), aes.BlockSize)
+	dst := make([]byte, 0, 2048)
+	b.SetBytes(1024)
+	for i := 0; i < b.N; i++ {
+		// the stream moves on, later packets decrypt to garbage but take the same work
+		o.Open(dst, 0, packet)
+	}
+}
@@ -0,0 +1,104 @@
+// Package sshmac implements the packet MACs umac-64@openssh.com and umac-128@openssh.com
+// of the SSH binary packet protocol, as described in the OpenSSH UMAC draft,
+// and their encrypt-then-MAC variants umac-64-etm@openssh.com and umac-128-etm@openssh.com.
+//
+// The MAC covers the unencrypted packet starting with its packet_length field,
+// the 32-bit packet sequence number is the nonce, as a big-endian 64-bit integer.
+// Any SSH transport can call Compute when sending and Verify when receiving a packet.
+//
+// In the encrypt-then-MAC mode the packet_length field is sent in clear,
+// and the MAC covers it and the ciphertext of the rest of the packet.
+// Sealer and Opener implement the whole packet processing of this mode over any cipher.Stream.
+package sshmac
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/fakeboboliu/umac"
+)
+
+// Algorithm names, as negotiated in the SSH key exchange.
+const (
+	UMAC64     = "umac-64@openssh.com"
+	UMAC128    = "umac-128@openssh.com"
+	UMAC64ETM  = "umac-64-etm@openssh.com"
+	UMAC128ETM = "umac-128-etm@openssh.com"
+)
+
+// KeySize is the length of the MAC key derived by the key exchange for both algorithms.
+const KeySize = 16
+
+// MAC computes and checks the packet MACs of one direction of a connection.
+// A MAC is read only and safe for concurrent use.
+type MAC struct {
+	name string
+	k8   *umac.Key8
+	k16  *umac.Key16
+}
+
+// New creates a MAC for the named algorithm with the 16-byte key of the key exchange.
+func New(name string, key []byte) (*MAC, error) {
+	if len(key) != KeySize {
+		return nil, umac.KeySizeError(len(key))
+	}
+	k, err := umac.NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	m := &MAC{name: name}
+	switch name {
+	case UMAC64, UMAC64ETM:
+		m.k8 = k.Key8()
+	case UMAC128, UMAC128ETM:
+		m.k16 = k.Key16()
+	default:
+		return nil, UnknownAlgorithmError(name)
+	}
+	return m, nil
+}
+
+// Name returns the algorithm name.
+func (m *MAC) Name() string {
+	return m.name
+}
+
+// ETM reports whether the algorithm is an encrypt-then-MAC one,
+// its packets are then sealed and opened with Sealer and Opener.
+func (m *MAC) ETM() bool {
+	return m.name == UMAC64ETM || m.name == UMAC128ETM
+}
+
+// Size returns the tag length, 8 for umac-64 and 16 for umac-128.
+func (m *MAC) Size() int {
+	if m.k8 != nil {
+		return 8
+	}
+	return 16
+}
+
+// Compute appends the tag of the packet with sequence number seq to dst and returns the result.
+// The packet is the unencrypted packet as sent on the wire without the MAC, that is
+// packet_length, padding_length, payload and padding.
+// It does not allocate if dst has room for the tag.
+func (m *MAC) Compute(dst []byte, seq uint32, packet []byte) []byte {
+	var nonce [umac.NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[:], uint64(seq))
+	if m.k8 != nil {
+		return m.k8.MAC(dst, nonce[:], packet)
+	}
+	return m.k16.MAC(dst, nonce[:], packet)
+}
+
+// Verify reports whether tag is the tag of the packet with sequence number seq.
+// Packets whose packet_length field does not match their length are rejected.
+func (m *MAC) Verify(seq uint32, packet, tag []byte) bool {
+	if len(packet) < 4 || uint64(binary.BigEndian.Uint32(packet)) != uint64(len(packet)-4) {
+		return false
+	}
+	if len(tag) != m.Size() {
+		return false
+	}
+	var buf [16]byte
+	return subtle.ConstantTimeCompare(m.Compute(buf[:0], seq, packet), tag) == 1
+}
@@ -0,0 +1,138 @@
+package sshmac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/fakeboboliu/umac"
+)
+
+// sshPacket is a SSH_MSG_USERAUTH_REQUEST packet captured from an OpenSSH client, sent with sequence number 3.
+const sshPacket = "000004bc0e320000000f7465737463657274696669636174650000000e7373682d636f6e6e656374696f6e000000097075626c69636b657900000000217273612d736861322d3531322d636572742d763031406f70656e7373682e636f6d000004500000001c7373682d7273612d636572742d763031406f70656e7373682e636f6d0000002010a47dc6785791b8bfa603faebd563047e97553611d32c75c2e9c2b4e223ce350000000301000100000101009eea3328cb5c4242089991927b822e8d2e3e2e46acf639a5062bf3896194df06a2be4a54bd8b298096e1eef4af9c738fb4ab1c74827edd45325620d4a0cef71ae9ac987bdf7910a803d6113992b87d047d1b46b5c1fa11aacac95c64e80b34efaff236288c29506d1b444f6b52fb16f8937dc60ae2f9c2095adbbf7466039082cee1b905231b44bc7355be118b7a7c8e1c584fc3784067bfdb2aaf24bcace6f43db33a59477b5c169dc324855984145f47a2e7a18db75d99e20003106945415fce9d5d0fbe74dc00c194974adf4e83e02788e0a2058aa13556b99f70c80ff1fb62d12d1be09b66bdebd8a0f77eff007d22d16abe173a9f2bb11743df587f92bf00000000000000000000000100000008757365726e616d65000000130000000f7465737463657274696669636174650000000000000000ffffffffffffffff0000000000000082000000157065726d69742d5831312d666f7277617264696e6700000000000000177065726d69742d6167656e742d666f7277617264696e6700000000000000167065726d69742d706f72742d666f7277617264696e67000000000000000a7065726d69742d707479000000000000000e7065726d69742d757365722d7263000000000000000000000117000000077373682d727361000000030100010000010100be0f5d43d2111b9f656096fe18449f2964dc878c81a6bed8770d6390aeafbedaf1f632e8e61900f17ebe12544f46a4c065294de5c066e9808071020eb265c3527e8e8f59553d00283a34c14efb233373631a1befe769074d8d27b0cb01798f6ae434ed9739a5624554ab66ba1ed81fda6362d35748c397c9eee4d3a3c11b35feced22dee73d6bc3f5f4769997934a8963781086647c1d96757611242541b068108f7744fc6ac4987f5020dab503e1a436f2bdeaebd99bc1f58e39aeab31e99566bb945797731f054d54db55bfe226b6762dcfc9bc83e2b4a65686a6d1e7dcab1a3a7012921dedba385a13b92f7381d1f488258bdfbbea385989ede1fdd4cde73000001140000000c7273612d736861322d353132000001008a567d92ec52588574d155b733d438b51cbcf583961a7b958185dd13838ef55e4370ef295c08adb75a7af38f51b1ad6d285820861d13ad527c505de8b5c4d5adf0738d37e79e69fac9499251e9a95ddd87783af797947353ea61033e0a918f8079e8e3e8637dcbce968595066567a53d297c92b2135a0938d5a77a234e3eae3cdc5cb8e9c6f4ee5e9843c2d9e68ce1062ccf872a1cd27d496584bbe1c8420a71e52335daa72babf2a137a589846dde74bd5802cc647f5fd31c471f8bac2fb078be0ca7b0279859f3bebffe36a98c704a03d481ee0b02acbc779bed1723c4e45946536491c44c91c756324df318d1bccbf981628bbd1b33ed27269cdd7e7242fa3e9a54afba3059a66f6e9ac87528"
+
+func TestOpenSSHPacket(t *testing.T) {
+	key, _ := hex.DecodeString("e5d3a843d10e9e66e77c97703491217c")
+	packet, _ := hex.DecodeString(sshPacket)
+	want, _ := hex.DecodeString("e03ab558b445896adb8a4a9bd64cacd4")
+
+	m, err := New(UMAC128, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag := m.Compute(nil, 3, packet); !bytes.Equal(tag, want) {
+		t.Errorf("Compute = %x, expected %x", tag, want)
+	}
+	if !m.Verify(3, packet, want) {
+		t.Error("Verify rejected the OpenSSH tag")
+	}
+}
+
+func TestCompute(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	packet := make([]byte, 4+1+1500+11)
+	binary.BigEndian.PutUint32(packet, uint32(len(packet)-4))
+	packet[4] = 11
+	for i := 5; i < len(packet); i++ {
+		packet[i] = byte(i)
+	}
+
+	for _, tc := range []struct {
+		name string
+		size int
+		ref  func([]byte) []byte
+	}{
+		{UMAC64, 8, func(nonce []byte) []byte {
+			h := umac.New8(key)
+			h.Write(packet)
+			return h.Sum(nonce)
+		}},
+		{UMAC128, 16, func(nonce []byte) []byte {
+			h := umac.New16(key)
+			h.Write(packet)
+			return h.Sum(nonce)
+		}},
+	} {
+		m, err := New(tc.name, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Name() != tc.name || m.Size() != tc.size {
+			t.Errorf("%s: Name, Size = %q, %d", tc.name, m.Name(), m.Size())
+		}
+		for _, seq := range []uint32{0, 1, 3, 1<<32 - 1} {
+			nonce := make([]byte, 8)
+			binary.BigEndian.PutUint64(nonce, uint64(seq))
+			want := tc.ref(nonce)
+			tag := m.Compute([]byte("prefix"), seq, packet)
+			if !bytes.Equal(tag[:6], []byte("prefix")) || !bytes.Equal(tag[6:], want) {
+				t.Errorf("%s: seq %d: Compute = %x, expected %x", tc.name, seq, tag, want)
+			}
+			if !m.Verify(seq, packet, want) {
+				t.Errorf("%s: seq %d: Verify rejected a valid tag", tc.name, seq)
+			}
+			if m.Verify(seq+1, packet, want) {
+				t.Errorf("%s: seq %d: Verify accepted the wrong sequence number", tc.name, seq)
+			}
+			if m.Verify(seq, packet, want[:len(want)-1]) {
+				t.Errorf("%s: seq %d: Verify accepted a truncated tag", tc.name, seq)
+			}
+			packet[100] ^= 1
+			if m.Verify(seq, packet, want) {
+				t.Errorf("%s: seq %d: Verify accepted a modified packet", tc.name, seq)
+			}
+			packet[100] ^= 1
+		}
+
+		if m.Verify(0, packet[:len(packet)-1], m.Compute(nil, 0, packet[:len(packet)-1])) {
+			t.Errorf("%s: Verify accepted a packet with a wrong length field", tc.name)
+		}
+		if m.Verify(0, packet[:3], m.Compute(nil, 0, packet[:3])) {
+			t.Errorf("%s: Verify accepted a packet without length field", tc.name)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	var kerr umac.KeySizeError
+	if _, err := New(UMAC64, make([]byte, 32)); !errors.As(err, &kerr) {
+		t.Errorf("New with a 32-byte key: err = %v, expected KeySizeError", err)
+	}
+	var aerr UnknownAlgorithmError
+	if _, err := New("hmac-sha1", make([]byte, KeySize)); !errors.As(err, &aerr) {
+		t.Errorf("New(\"hmac-sha1\"): err = %v, expected UnknownAlgorithmError", err)
+	}
+}
+
+func TestNoAlloc(t *testing.T) {
+	packet := make([]byte, 64)
+	binary.BigEndian.PutUint32(packet, 60)
+	dst := make([]byte, 0, 32)
+	for _, name := range []string{UMAC64, UMAC128} {
+		m, _ := New(name, make([]byte, KeySize))
+		tag := m.Compute(nil, 7, packet)
+		if n := testing.AllocsPerRun(100, func() {
+			m.Compute(dst, 7, packet)
+			m.Verify(7, packet, tag)
+		}); n != 0 {
+			t.Errorf("%s: %v allocations, expected 0", name, n)
+		}
+	}
+}
+
+func BenchmarkCompute(b *testing.B) {
+	packet := make([]byte, 1024)
+	binary.BigEndian.PutUint32(packet, uint32(len(packet)-4))
+	dst := make([]byte, 0, 32)
+	for _, name := range []string{UMAC64, UMAC128} {
+		m, _ := New(name, make([]byte, KeySize))
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(packet)))
+			for i := 0; i < b.N; i++ {
+				m.Compute(dst, uint32(i), packet)
+			}
+		})
+	}
+}
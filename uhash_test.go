@@ -0,0 +1,160 @@
+package umac
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// zeroLong8 computes UHASH-64 of total zero bytes block by block, without any length bookkeeping.
+func zeroLong8(k *Key, total uint64, out []byte) {
+	var (
+		u   uhash8
+		res [STREAMS8]uint64
+	)
+	u.init(k)
+	zero := make([]byte, L1_KEY_LEN)
+	u.nh.hash(zero, L1_KEY_LEN, L1_KEY_LEN, res[:])
+	for i := uint64(0); i < total/L1_KEY_LEN; i++ {
+		u.polyHash(res[:])
+	}
+	if r := int(total % L1_KEY_LEN); r != 0 {
+		nhLen := (r + (L1_PAD_BOUNDARY - 1)) & ^(L1_PAD_BOUNDARY - 1)
+		u.nh.hash(zero, nhLen, r, res[:])
+		u.polyHash(res[:])
+	}
+	u.ipLong(out)
+}
+
+func TestUHASHOver4GiB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams more than 4 GiB")
+	}
+	k, _ := NewKey([]byte("abcdefghijklmnop"))
+	const total = 1<<32 + 3*L1_KEY_LEN + 100
+
+	var u uhash8
+	u.init(k)
+	chunk := make([]byte, 1<<20+13)
+	for left := uint64(total); left > 0; {
+		n := uint64(len(chunk))
+		if left < n {
+			n = left
+		}
+		u.update(chunk[:n])
+		left -= n
+	}
+	if u.msgLen != total {
+		t.Fatalf("msgLen is %d, expected %d", u.msgLen, uint64(total))
+	}
+
+	var got, want [8]byte
+	u.final(got[:])
+	zeroLong8(k, total, want[:])
+	if !bytes.Equal(got[:], want[:]) {
+		t.Errorf("UHASH-64 of %d zero bytes: %x, expected %x", uint64(total), got, want)
+	}
+}
+
+// polyRef is one step of POLY of section 5.3 of RFC 4418, on big integers:
+// words in the top 2^32 of the 64-bit range are sent as the marker p64 - 1 followed by word - 59.
+func polyRef(cur, key, data uint64) uint64 {
+	p := new(big.Int).SetUint64(p64)
+	y := new(big.Int).Mod(new(big.Int).SetUint64(cur), p)
+	k := new(big.Int).SetUint64(key)
+	step := func(m uint64) {
+		y.Mul(y, k)
+		y.Add(y, new(big.Int).SetUint64(m))
+		y.Mod(y, p)
+	}
+	if data >= 1<<64-1<<32 {
+		step(p64 - 1)
+		step(data - 59)
+	} else {
+		step(data)
+	}
+	return y.Uint64()
+}
+
+var polyEdges = []uint64{0, 1, 58, 59, 60, 1<<64 - 1<<32 - 1, 1<<64 - 1<<32, 1<<64 - 1<<32 + 58, p64 - 1, p64, 1<<64 - 1}
+
+func TestPoly64(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		cur, data := rnd.Uint64(), rnd.Uint64()
+		if i < len(polyEdges)*len(polyEdges) {
+			cur, data = polyEdges[i/len(polyEdges)], polyEdges[i%len(polyEdges)]
+		}
+		key := rnd.Uint64() & (0x01ffffff<<32 + 0x01ffffff)
+		if got, want := polyStep(cur, key, data), polyRef(cur, key, data); reduceP64(got) != want {
+			t.Fatalf("polyStep(%x, %x, %x) = %x, expected %x", cur, key, data, got, want)
+		}
+		if data>>32 == 0xffffffff {
+			// polyHash never passes values above p64 - 1 to poly64Ref
+			data = p64 - 1
+		}
+		if got, want := poly64Generic(cur, key, data), poly64Ref(cur, key, data); reduceP64(got) != reduceP64(want) {
+			t.Fatalf("poly64Generic(%x, %x, %x) = %x, expected %x", cur, key, data, got, want)
+		}
+	}
+}
+
+// poly64Ref is the original poly64 built from 32-bit partial products.
+func poly64Ref(cur uint64, key uint64, data uint64) uint64 {
+	keyHi := uint32(key >> 32)
+	keyLo := uint32(key)
+	curHi := uint32(cur >> 32)
+	curLo := uint32(cur)
+	x := uint64(keyHi)*uint64(curLo) + uint64(curHi)*uint64(keyLo)
+	xLo := uint32(x)
+	xHi := uint32(x >> 32)
+	res := (uint64(keyHi)*uint64(curHi)+uint64(xHi))*59 + uint64(keyLo)*uint64(curLo)
+	t := uint64(xLo) << 32
+	res += t
+	if res < t {
+		res += 59
+	}
+	res += data
+	if res < data {
+		res += 59
+	}
+	return res
+}
+
+// FuzzPoly64 checks a polynomial step, marker path included, against the RFC rule
+// on the full 64-bit range of the accumulator and the data.
+func FuzzPoly64(f *testing.F) {
+	for _, e := range polyEdges {
+		f.Add(e, uint64(0x01ffffff01ffffff), e)
+	}
+	f.Add(uint64(1<<64-1), uint64(0x01ffffff01ffffff), uint64(1<<64-1-0xffffffff))
+	f.Fuzz(func(t *testing.T, cur, key, data uint64) {
+		key &= 0x01ffffff<<32 + 0x01ffffff
+		if got, want := polyStep(cur, key, data), polyRef(cur, key, data); reduceP64(got) != want {
+			t.Fatalf("polyStep(%x, %x, %x) = %x, expected %x", cur, key, data, got, want)
+		}
+		// without the marker rule, poly64Generic is cur*key + data mod p64 for any data
+		want := new(big.Int).SetUint64(cur)
+		want.Mul(want, new(big.Int).SetUint64(key))
+		want.Add(want, new(big.Int).SetUint64(data))
+		want.Mod(want, new(big.Int).SetUint64(p64))
+		if got := poly64Generic(cur, key, data); reduceP64(got) != want.Uint64() {
+			t.Fatalf("poly64Generic(%x, %x, %x) = %x, expected %x", cur, key, data, got, want)
+		}
+	})
+}
+
+func BenchmarkPoly64(b *testing.B) {
+	cur, key := uint64(0x0123456789abcdef), uint64(0x01234567_01abcdef)
+	b.Run("ref", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cur = poly64Ref(cur, key, uint64(i))
+		}
+	})
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cur = poly64Generic(cur, key, uint64(i))
+		}
+	})
+}
@@ -1,10 +1,10 @@
 package umac
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/subtle"
+	"encoding/binary"
 	"hash"
 	"math"
 )
@@ -28,62 +28,123 @@ func kdf(cip cipher.Block, index uint8, dst []byte) []byte {
 }
 
 type pdfCtx struct {
-	cip   cipher.Block        // AES cipher for pdf
+	cip   cipher.Block        // block cipher for pdf, shared with the Key
 	cache [aes.BlockSize]byte // cache from previous aes output
-	nonce [aes.BlockSize]byte // nonce for aes, the input
+	last  uint64              // masked nonce the cache belongs to
+	in    [aes.BlockSize]byte // nonce for aes, the input
+	pads  []padEntry          // precomputed pads, nil until Precompute is called
+	// shared is set when pads may be referenced by a clone, it is copied before being written
+	shared bool
 }
 
-func (c *pdfCtx) init(cip cipher.Block) {
-	// reuse the cache to store kdf output, it will be rewritten soon
-	kdf(cip, 0, c.cache[:])
+// NonceSize is the size of UMAC nonces in bytes.
+const NonceSize = 8
 
-	// the input of NewCipher is controlled, so we can always ignore the error
-	c.cip, _ = aes.NewCipher(c.cache[:])
+func nonceOf(b []byte) [NonceSize]byte {
+	if len(b) < NonceSize {
+		panic(NonceSizeError(len(b)))
+	}
+	return [NonceSize]byte(b)
+}
+
+func (c *pdfCtx) init(k *Key) {
+	c.cip = k.pad
+	// aes(kdf(key), {0*16}) is precomputed, it matches the zero nonce
+	c.cache = k.padZero
+	c.last = 0
+	c.in = [aes.BlockSize]byte{}
+	c.pads = c.pads[:0]
+}
 
-	// store aes(kdf(key), {0*16}) -> cache
-	// nonce is 0 now, so we use it as input
-	c.cip.Encrypt(c.cache[:], c.nonce[:])
+func (c *pdfCtx) genXor4(nonce [8]byte, buf []byte) {
+	_ = buf[3]
+	n := binary.BigEndian.Uint64(nonce[:])
+	subtle.XORBytes(buf, buf, c.pad(n, 2)[n&3*4:])
 }
 
 func (c *pdfCtx) genXor8(nonce [8]byte, buf []byte) {
 	_ = buf[7]
+	n := binary.BigEndian.Uint64(nonce[:])
+	subtle.XORBytes(buf, buf, c.pad(n, 1)[n&1*8:])
+}
 
-	const loBitMask = 0x01
-	ndx := nonce[7] & loBitMask
-	var t [4]byte
-	copy(t[:], nonce[4:])
-	t[3] &= ^byte(loBitMask)
+func (c *pdfCtx) genXor16(nonce [8]byte, buf []byte) {
+	_ = buf[15]
+	n := binary.BigEndian.Uint64(nonce[:])
+	subtle.XORBytes(buf, buf, c.pad(n, 0))
+}
 
-	if !bytes.Equal(t[:], c.nonce[4:]) || !bytes.Equal(nonce[:4], c.nonce[:4]) {
-		copy(c.nonce[:4], nonce[:4])
-		copy(c.nonce[4:8], t[:])
-		c.cip.Encrypt(c.cache[:], c.nonce[:])
-	}
+func (c *pdfCtx) genXor12(nonce [8]byte, buf []byte) {
+	_ = buf[11]
+	n := binary.BigEndian.Uint64(nonce[:])
+	subtle.XORBytes(buf, buf, c.pad(n, 0)[:12])
+}
 
-	subtle.XORBytes(buf, buf, c.cache[ndx*8:])
+// UMAC4 is the 4-byte output version of UMAC.
+// also known as UMAC-32
+type UMAC4 struct {
+	pdf    pdfCtx
+	hash   uhash4
+	out    [4]byte
+	minTag int // shortest tag accepted by Verify, 0 for the full size
 }
 
-func (c *pdfCtx) genXor16(nonce [8]byte, buf []byte) {
-	_ = buf[7]
+func (u *UMAC4) Write(p []byte) (n int, err error) {
+	u.hash.update(p)
+	return len(p), nil
+}
 
-	var t [4]byte
-	copy(t[:], nonce[4:])
+// Sum uses the argument as nonce, which should be 8 bytes long.
+// It panics with a NonceSizeError if b is shorter than that.
+// WARNING: it's not standard hash.Hash behavior.
+func (u *UMAC4) Sum(b []byte) []byte {
+	nonce := nonceOf(b)
+	out := u.out[:]
+	u.hash.final(out)
+	u.pdf.genXor4(nonce, out)
+	b = b[:0]
+	return append(b, out...)
+}
 
-	if !bytes.Equal(t[:], c.nonce[4:]) || !bytes.Equal(nonce[:4], c.nonce[:4]) {
-		copy(c.nonce[:4], nonce[:4])
-		copy(c.nonce[4:8], t[:])
-		c.cip.Encrypt(c.cache[:], c.nonce[:])
-	}
+// tag writes the tag of the data written so far to out, the state is left untouched.
+func (u *UMAC4) tag(nonce [8]byte, out []byte) {
+	h := u.hash
+	h.final(out)
+	u.pdf.genXor4(nonce, out)
+}
+
+func (u *UMAC4) Reset() {
+	u.hash.reset()
+}
 
-	subtle.XORBytes(buf, buf, c.cache[:])
+// Clone returns an independent copy of the hasher, sharing the key schedule,
+// so a common prefix can be hashed once and followed by different messages.
+// Only the running state is copied, precomputed pads are shared until either side changes them.
+func (u *UMAC4) Clone() *UMAC4 {
+	u.pdf.share()
+	c := *u
+	return &c
+}
+
+func (u *UMAC4) clone() umac {
+	return u.Clone()
+}
+
+func (u *UMAC4) Size() int {
+	return 4
+}
+
+func (u *UMAC4) BlockSize() int {
+	return 1
 }
 
 // UMAC8 is the 8-byte output version of UMAC.
 // also known as UMAC-64
 type UMAC8 struct {
-	pdf  pdfCtx
-	hash uhash8
-	out  [8]byte
+	pdf    pdfCtx
+	hash   uhash8
+	out    [8]byte
+	minTag int // shortest tag accepted by Verify, 0 for the full size
 }
 
 func (u *UMAC8) Write(p []byte) (n int, err error) {
@@ -92,20 +153,41 @@ func (u *UMAC8) Write(p []byte) (n int, err error) {
 }
 
 // Sum uses the argument as nonce, which should be 8 bytes long.
+// It panics with a NonceSizeError if b is shorter than that.
 // WARNING: it's not standard hash.Hash behavior.
 func (u *UMAC8) Sum(b []byte) []byte {
-	_ = b[7]
+	nonce := nonceOf(b)
 	out := u.out[:]
 	u.hash.final(out)
-	u.pdf.genXor8([8]byte(b), out)
+	u.pdf.genXor8(nonce, out)
 	b = b[:0]
 	return append(b, out...)
 }
 
+// tag writes the tag of the data written so far to out, the state is left untouched.
+func (u *UMAC8) tag(nonce [8]byte, out []byte) {
+	h := u.hash
+	h.final(out)
+	u.pdf.genXor8(nonce, out)
+}
+
 func (u *UMAC8) Reset() {
 	u.hash.reset()
 }
 
+// Clone returns an independent copy of the hasher, sharing the key schedule,
+// so a common prefix can be hashed once and followed by different messages.
+// Only the running state is copied, precomputed pads are shared until either side changes them.
+func (u *UMAC8) Clone() *UMAC8 {
+	u.pdf.share()
+	c := *u
+	return &c
+}
+
+func (u *UMAC8) clone() umac {
+	return u.Clone()
+}
+
 func (u *UMAC8) Size() int {
 	return 8
 }
@@ -114,12 +196,71 @@ func (u *UMAC8) BlockSize() int {
 	return 1
 }
 
+// UMAC12 is the 12-byte output version of UMAC.
+// also known as UMAC-96
+type UMAC12 struct {
+	pdf    pdfCtx
+	hash   uhash12
+	out    [12]byte
+	minTag int // shortest tag accepted by Verify, 0 for the full size
+}
+
+func (u *UMAC12) Write(p []byte) (n int, err error) {
+	u.hash.update(p)
+	return len(p), nil
+}
+
+// Sum uses the argument as nonce, which should be 8 bytes long.
+// It panics with a NonceSizeError if b is shorter than that.
+// WARNING: it's not standard hash.Hash behavior.
+func (u *UMAC12) Sum(b []byte) []byte {
+	nonce := nonceOf(b)
+	out := u.out[:]
+	u.hash.final(out)
+	u.pdf.genXor12(nonce, out)
+	b = b[:0]
+	return append(b, out...)
+}
+
+// tag writes the tag of the data written so far to out, the state is left untouched.
+func (u *UMAC12) tag(nonce [8]byte, out []byte) {
+	h := u.hash
+	h.final(out)
+	u.pdf.genXor12(nonce, out)
+}
+
+func (u *UMAC12) Reset() {
+	u.hash.reset()
+}
+
+// Clone returns an independent copy of the hasher, sharing the key schedule,
+// so a common prefix can be hashed once and followed by different messages.
+// Only the running state is copied, precomputed pads are shared until either side changes them.
+func (u *UMAC12) Clone() *UMAC12 {
+	u.pdf.share()
+	c := *u
+	return &c
+}
+
+func (u *UMAC12) clone() umac {
+	return u.Clone()
+}
+
+func (u *UMAC12) Size() int {
+	return 12
+}
+
+func (u *UMAC12) BlockSize() int {
+	return 1
+}
+
 // UMAC16 is the 16-byte output version of UMAC.
 // also known as UMAC-128
 type UMAC16 struct {
-	pdf  pdfCtx
-	hash uhash16
-	out  [16]byte
+	pdf    pdfCtx
+	hash   uhash16
+	out    [16]byte
+	minTag int // shortest tag accepted by Verify, 0 for the full size
 }
 
 func (u *UMAC16) Write(p []byte) (n int, err error) {
@@ -128,20 +269,41 @@ func (u *UMAC16) Write(p []byte) (n int, err error) {
 }
 
 // Sum uses the argument as nonce, which should be 8 bytes long.
+// It panics with a NonceSizeError if b is shorter than that.
 // WARNING: it's not standard hash.Hash behavior.
 func (u *UMAC16) Sum(b []byte) []byte {
-	_ = b[7]
+	nonce := nonceOf(b)
 	out := u.out[:]
 	u.hash.final(out)
-	u.pdf.genXor16([8]byte(b), out)
+	u.pdf.genXor16(nonce, out)
 	b = b[:0]
 	return append(b, out...)
 }
 
+// tag writes the tag of the data written so far to out, the state is left untouched.
+func (u *UMAC16) tag(nonce [8]byte, out []byte) {
+	h := u.hash
+	h.final(out)
+	u.pdf.genXor16(nonce, out)
+}
+
 func (u *UMAC16) Reset() {
 	u.hash.reset()
 }
 
+// Clone returns an independent copy of the hasher, sharing the key schedule,
+// so a common prefix can be hashed once and followed by different messages.
+// Only the running state is copied, precomputed pads are shared until either side changes them.
+func (u *UMAC16) Clone() *UMAC16 {
+	u.pdf.share()
+	c := *u
+	return &c
+}
+
+func (u *UMAC16) clone() umac {
+	return u.Clone()
+}
+
 func (u *UMAC16) Size() int {
 	return 16
 }
@@ -150,24 +312,74 @@ func (u *UMAC16) BlockSize() int {
 	return 1
 }
 
+// NewUMAC4 creates a UMAC-32 instance with the given AES key.
+func NewUMAC4(key []byte) (*UMAC4, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.New4(), nil
+}
+
+// NewUMAC8 creates a UMAC-64 instance with the given AES key.
+func NewUMAC8(key []byte) (*UMAC8, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.New8(), nil
+}
+
+// NewUMAC12 creates a UMAC-96 instance with the given AES key.
+func NewUMAC12(key []byte) (*UMAC12, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.New12(), nil
+}
+
+// NewUMAC16 creates a UMAC-128 instance with the given AES key.
+func NewUMAC16(key []byte) (*UMAC16, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.New16(), nil
+}
+
+// New4 is like NewUMAC4, but panics if the key is invalid.
+func New4(key []byte) hash.Hash {
+	u, err := NewUMAC4(key)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// New8 is like NewUMAC8, but panics if the key is invalid.
 func New8(key []byte) hash.Hash {
-	u := &UMAC8{}
-	cip, err := aes.NewCipher(key)
+	u, err := NewUMAC8(key)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// New12 is like NewUMAC12, but panics if the key is invalid.
+func New12(key []byte) hash.Hash {
+	u, err := NewUMAC12(key)
 	if err != nil {
 		panic(err)
 	}
-	u.pdf.init(cip)
-	u.hash.init(cip)
 	return u
 }
 
+// New16 is like NewUMAC16, but panics if the key is invalid.
 func New16(key []byte) hash.Hash {
-	u := &UMAC16{}
-	cip, err := aes.NewCipher(key)
+	u, err := NewUMAC16(key)
 	if err != nil {
 		panic(err)
 	}
-	u.pdf.init(cip)
-	u.hash.init(cip)
 	return u
 }
@@ -5,8 +5,10 @@ import (
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"hash"
+	"strconv"
 	"testing"
 )
 
@@ -64,6 +66,61 @@ func TestUMAC(t *testing.T) {
 	}
 }
 
+// Test vectors from RFC 4418 Appendix, key "abcdefghijklmnop" and nonce "bcdefghi"
+var rfcVectors = []struct {
+	pattern string
+	count   int
+	tag4    string
+	tag8    string
+	tag12   string
+}{
+	{"", 0, "113145FB", "6E155FAD26900BE1", "32FEDB100C79AD58F07FF764"},
+	{"a", 3, "3B91D102", "44B5CB542F220104", "185E4FE905CBA7BD85E4C2DC"},
+	{"a", 1 << 10, "599B350B", "26BF2F5D60118BD9", "7A54ABE04AF82D60FB298C3C"},
+	{"a", 1 << 15, "58DCF532", "27F8EF643B0D118D", "7B136BD911E4B734286EF2BE"},
+	{"a", 1 << 20, "DB6364D1", "A4477E87E9F55853", "F8ACFA3AC31CFEEA047F7B11"},
+	{"a", 1 << 25, "5109A660", "2E2DBC36860A0A5F", "72C6388BACE3ACE6FBF062D9"},
+	{"abc", 1, "ABF3A3A0", "D4D7B9F6BD4FBFCF", "883C3D4B97A61976FFCF2323"},
+	{"abc", 500, "ABEB3C8B", "D4CF26DDEFD5C01A", "8824A260C53C66A36C9260A6"},
+}
+
+func TestRFC4418(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	for _, v := range rfcVectors {
+		msg := bytes.Repeat([]byte(v.pattern), v.count)
+		for _, c := range []struct {
+			h   hash.Hash
+			tag string
+		}{{New4(key), v.tag4}, {New8(key), v.tag8}, {New12(key), v.tag12}} {
+			c.h.Write(msg)
+			tag := c.h.Sum([]byte("bcdefghi"))
+			target, _ := hex.DecodeString(c.tag)
+			if !bytes.Equal(tag, target) {
+				t.Errorf("UMAC-%d of %q*%d failed: %X, expected %s", c.h.Size()*8, v.pattern, v.count, tag, c.tag)
+			}
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := NewUMAC8(make([]byte, 15)); err != KeySizeError(15) {
+		t.Errorf("NewUMAC8 with short key: %v", err)
+	}
+	if _, err := NewUMAC16(nil); err != KeySizeError(0) {
+		t.Errorf("NewUMAC16 with nil key: %v", err)
+	}
+	if _, err := NewUMAC8(make([]byte, 16)); err != nil {
+		t.Errorf("NewUMAC8 with valid key: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r != NonceSizeError(4) {
+			t.Errorf("Sum with short nonce panicked with %v", r)
+		}
+	}()
+	New8(make([]byte, 16)).Sum(make([]byte, 4))
+}
+
 func benchHash(b *testing.B, h hash.Hash, buf []byte) {
 	b.SetBytes(int64(len(buf)))
 	for i := 0; i < b.N; i++ {
@@ -74,11 +131,15 @@ func benchHash(b *testing.B, h hash.Hash, buf []byte) {
 	}
 }
 
+// benchUMAC and benchKey use an increasing nonce, as a sender would,
+// so the pad costs the same as in real use and the two can be compared.
 func benchUMAC(b *testing.B, h hash.Hash, buf []byte) {
 	b.SetBytes(int64(len(buf)))
+	var nonce [NonceSize]byte
 	for i := 0; i < b.N; i++ {
 		h.Write(buf)
-		mac := h.Sum([]byte("abcdefgh"))
+		binary.BigEndian.PutUint64(nonce[:], uint64(i))
+		mac := h.Sum(nonce[:])
 		h.Reset()
 		buf[1] = mac[1]
 	}
@@ -108,6 +169,18 @@ func BenchmarkHMACMD5_32(b *testing.B) {
 	benchHash(b, hmac.New(md5.New, key), buf)
 }
 
+func BenchmarkUMAC32_1K(b *testing.B) {
+	key := make([]byte, 16)
+	buf := make([]byte, 1024)
+	benchUMAC(b, New4(key), buf)
+}
+
+func BenchmarkUMAC32_32(b *testing.B) {
+	key := make([]byte, 16)
+	buf := make([]byte, 32)
+	benchUMAC(b, New4(key), buf)
+}
+
 func BenchmarkUMAC64_1K(b *testing.B) {
 	key := make([]byte, 16)
 	buf := make([]byte, 1024)
@@ -120,6 +193,18 @@ func BenchmarkUMAC64_32(b *testing.B) {
 	benchUMAC(b, New8(key), buf)
 }
 
+func BenchmarkUMAC96_1K(b *testing.B) {
+	key := make([]byte, 16)
+	buf := make([]byte, 1024)
+	benchUMAC(b, New12(key), buf)
+}
+
+func BenchmarkUMAC96_32(b *testing.B) {
+	key := make([]byte, 16)
+	buf := make([]byte, 32)
+	benchUMAC(b, New12(key), buf)
+}
+
 func BenchmarkUMAC128_1K(b *testing.B) {
 	key := make([]byte, 16)
 	buf := make([]byte, 1024)
@@ -131,3 +216,48 @@ func BenchmarkUMAC128_32(b *testing.B) {
 	buf := make([]byte, 32)
 	benchUMAC(b, New16(key), buf)
 }
+
+func benchKey(b *testing.B, k macer, buf []byte) {
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	var nonce [NonceSize]byte
+	dst := make([]byte, 0, 16)
+	for i := 0; i < b.N; i++ {
+		binary.BigEndian.PutUint64(nonce[:], uint64(i))
+		mac := k.MAC(dst, nonce[:], buf)
+		buf[1] = mac[1]
+	}
+}
+
+func BenchmarkKey64_1K(b *testing.B) {
+	k, _ := NewKey8(make([]byte, 16))
+	benchKey(b, k, make([]byte, 1024))
+}
+
+func BenchmarkKey64_32(b *testing.B) {
+	k, _ := NewKey8(make([]byte, 16))
+	benchKey(b, k, make([]byte, 32))
+}
+
+func BenchmarkKey128_1K(b *testing.B) {
+	k, _ := NewKey16(make([]byte, 16))
+	benchKey(b, k, make([]byte, 1024))
+}
+
+func BenchmarkKey128_32(b *testing.B) {
+	k, _ := NewKey16(make([]byte, 16))
+	benchKey(b, k, make([]byte, 32))
+}
+
+func BenchmarkUMACLong(b *testing.B) {
+	key := make([]byte, 16)
+	for _, size := range []int{2 << 10, 8 << 10, 64 << 10} {
+		buf := make([]byte, size)
+		b.Run("64_"+strconv.Itoa(size>>10)+"K", func(b *testing.B) {
+			benchUMAC(b, New8(key), buf)
+		})
+		b.Run("128_"+strconv.Itoa(size>>10)+"K", func(b *testing.B) {
+			benchUMAC(b, New16(key), buf)
+		})
+	}
+}
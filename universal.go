@@ -0,0 +1,255 @@
+package umac
+
+import "encoding/binary"
+
+// UHASH is the keyed universal hash of UMAC, section 5 of RFC 4418,
+// without the pad that turns it into a MAC.
+//
+// It is almost-universal: two different messages collide under a random key with
+// a probability of about 2^-30 per 4 bytes of output. It is not a PRF though,
+// outputs of chosen messages reveal information about the key, so they must be kept secret.
+// It is meant for uses like hash tables hardened against collision attacks.
+//
+// UHASH implements hash.Hash, Sum appends the hash without changing the state.
+type UHASH struct {
+	h    uhasher
+	size int
+	out  [16]byte
+}
+
+type uhasher interface {
+	update(buf []byte)
+	final(out []byte)
+	reset()
+}
+
+// NewUHASH creates a UHASH with the given AES key and output size,
+// which should be one of 4, 8, 12 and 16.
+func NewUHASH(key []byte, size int) (*UHASH, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.NewUHASH(size)
+}
+
+// NewUHASH creates a UHASH using the key schedule, size should be one of 4, 8, 12 and 16.
+// It hashes like the UMAC of the same size, before the pad is applied.
+func (k *Key) NewUHASH(size int) (*UHASH, error) {
+	var h uhasher
+	switch size {
+	case 4:
+		u := &uhash4{}
+		u.init(k)
+		h = u
+	case 8:
+		u := &uhash8{}
+		u.init(k)
+		h = u
+	case 12:
+		u := &uhash12{}
+		u.init(k)
+		h = u
+	case 16:
+		u := &uhash16{}
+		u.init(k)
+		h = u
+	default:
+		return nil, TagSizeError(size)
+	}
+	return &UHASH{h: h, size: size}, nil
+}
+
+func (u *UHASH) Write(p []byte) (n int, err error) {
+	u.h.update(p)
+	return len(p), nil
+}
+
+// Sum appends the hash of the data written so far to b.
+func (u *UHASH) Sum(b []byte) []byte {
+	out := u.out[:u.size]
+	// final resets the state, so it runs on a copy
+	switch h := u.h.(type) {
+	case *uhash4:
+		c := *h
+		c.final(out)
+	case *uhash8:
+		c := *h
+		c.final(out)
+	case *uhash12:
+		c := *h
+		c.final(out)
+	case *uhash16:
+		c := *h
+		c.final(out)
+	}
+	return append(b, out...)
+}
+
+func (u *UHASH) Reset() {
+	u.h.reset()
+}
+
+func (u *UHASH) Size() int {
+	return u.size
+}
+
+func (u *UHASH) BlockSize() int {
+	return 1
+}
+
+// MaxNHKeyLen is the largest NH key length, the longest message NH can hash.
+const MaxNHKeyLen = 2048
+
+// NH is the NH hash family of UMAC, the first layer of UHASH (section 5.2 of RFC 4418),
+// with a configurable number of streams and key length.
+//
+// A message of at most the key length is zero padded to a multiple of L1_PAD_BOUNDARY (32) bytes,
+// the empty message to 32 zero bytes, and every stream outputs NH of the padded message
+// plus the message length in bits, mod 2^64, like the L1 hash of UMAC.
+// Stream i uses the key shifted by i*L1_KEY_SHIFT bytes, so with a key length of L1_KEY_LEN
+// and 1 to 4 streams NH gives the L1 hash of UMAC-32 to UMAC-128 for a single block.
+//
+// NH is almost-universal: two different messages collide in one stream under a random key
+// with a probability of at most 2^-32, and in all n streams with at most 2^-32n.
+// It is not a MAC or a PRF, its outputs are sums of products of message and key words
+// that leak key material, so they must be kept secret, and messages must not depend on earlier outputs.
+//
+// Hash computes NH in one shot and does not touch the streaming state of Write and Sum64,
+// it is safe for concurrent use.
+type NH struct {
+	key     []uint32
+	streams int
+	keyLen  int
+
+	tail   [L1_PAD_BOUNDARY]byte
+	state  [STREAMS16]uint64
+	hashed int // bytes hashed into state
+	n      int // bytes in tail
+}
+
+// NewNH creates an NH instance with the given AES key, streams streams, from 1 to 4,
+// and keyLen bytes of key per stream, a multiple of L1_PAD_BOUNDARY not larger than MaxNHKeyLen.
+func NewNH(key []byte, streams, keyLen int) (*NH, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.NewNH(streams, keyLen)
+}
+
+// NewNH creates an NH instance using the key schedule, with streams streams and keyLen bytes
+// of key per stream, see the package level NewNH.
+// The NH key is derived like the one of UMAC, with a key length of L1_KEY_LEN
+// it is the NH key of the MACs of k.
+func (k *Key) NewNH(streams, keyLen int) (*NH, error) {
+	if streams < 1 || streams > STREAMS16 || keyLen < L1_PAD_BOUNDARY || keyLen > MaxNHKeyLen || keyLen%L1_PAD_BOUNDARY != 0 {
+		return nil, ErrNHParams
+	}
+	buf := make([]byte, keyLen+L1_KEY_SHIFT*(streams-1))
+	kdf(k.kdf, 1, buf)
+	h := &NH{key: make([]uint32, len(buf)/4), streams: streams, keyLen: keyLen}
+	for i := range h.key {
+		h.key[i] = binary.BigEndian.Uint32(buf[4*i:])
+	}
+	return h, nil
+}
+
+// aux runs the NH kernel of the stream count, adding NH of d under k to hp.
+func (h *NH) aux(k []uint32, d []byte, hp []uint64) {
+	switch h.streams {
+	case 1:
+		nhAux4(k, d, hp, len(d))
+	case 2:
+		nhAux8(k, d, hp, len(d))
+	case 3:
+		nhAux12(k, d, hp, len(d))
+	default:
+		nhAux16(k, d, hp, len(d))
+	}
+}
+
+// Hash appends the outputs of the streams for msg to dst,
+// msg must not be longer than the key length.
+func (h *NH) Hash(dst []uint64, msg []byte) ([]uint64, error) {
+	if len(msg) > h.keyLen {
+		return dst, ErrNHTooLong
+	}
+	var out [STREAMS16]uint64
+	res := out[:h.streams]
+	for i := range res {
+		res[i] = uint64(len(msg)) * 8
+	}
+	full := len(msg) &^ (L1_PAD_BOUNDARY - 1)
+	if full != 0 {
+		h.aux(h.key, msg[:full], res)
+	}
+	if full != len(msg) || full == 0 {
+		var tail [L1_PAD_BOUNDARY]byte
+		copy(tail[:], msg[full:])
+		h.aux(h.key[full/4:], tail[:], res)
+	}
+	return append(dst, res...), nil
+}
+
+// Write adds p to the message, it fails with ErrNHTooLong and writes nothing
+// if the message would get longer than the key length.
+func (h *NH) Write(p []byte) (int, error) {
+	if h.hashed+h.n+len(p) > h.keyLen {
+		return 0, ErrNHTooLong
+	}
+	n := len(p)
+	if h.n != 0 {
+		c := copy(h.tail[h.n:], p)
+		h.n += c
+		p = p[c:]
+		if h.n < L1_PAD_BOUNDARY {
+			return n, nil
+		}
+		h.aux(h.key[h.hashed/4:], h.tail[:], h.state[:h.streams])
+		h.hashed += L1_PAD_BOUNDARY
+		h.n = 0
+	}
+	if full := len(p) &^ (L1_PAD_BOUNDARY - 1); full != 0 {
+		h.aux(h.key[h.hashed/4:], p[:full], h.state[:h.streams])
+		h.hashed += full
+		p = p[full:]
+	}
+	h.n = copy(h.tail[:], p)
+	return n, nil
+}
+
+// Sum64 appends the outputs of the streams for the message written so far to dst,
+// the state is not changed.
+func (h *NH) Sum64(dst []uint64) []uint64 {
+	out := h.state
+	res := out[:h.streams]
+	if h.n != 0 || h.hashed == 0 {
+		tail := h.tail
+		for i := h.n; i < L1_PAD_BOUNDARY; i++ {
+			tail[i] = 0
+		}
+		h.aux(h.key[h.hashed/4:], tail[:], res)
+	}
+	for i := range res {
+		res[i] += uint64(h.hashed+h.n) * 8
+	}
+	return append(dst, res...)
+}
+
+// Reset clears the message.
+func (h *NH) Reset() {
+	h.state = [STREAMS16]uint64{}
+	h.hashed = 0
+	h.n = 0
+}
+
+// Streams returns the number of streams, the number of outputs of Hash and Sum64.
+func (h *NH) Streams() int {
+	return h.streams
+}
+
+// KeyLen returns the key length, the longest message NH can hash.
+func (h *NH) KeyLen() int {
+	return h.keyLen
+}
@@ -0,0 +1,213 @@
+package umac
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+// TestUHASH checks that UHASH xored with a pad computed independently, as in section 4 of RFC 4418,
+// gives the tags of the RFC test vectors.
+func TestUHASH(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("bcdefghi")
+	cip, _ := aes.NewCipher(key)
+	var padKey [aes.BlockSize]byte
+	kdf(cip, 0, padKey[:])
+	pdf, _ := aes.NewCipher(padKey[:])
+	pad := func(size int) []byte {
+		var block [aes.BlockSize]byte
+		copy(block[:], nonce)
+		index := 0
+		if size <= 8 {
+			mask := byte(16/size - 1)
+			index = int(nonce[7]&mask) * size
+			block[7] &^= mask
+		}
+		pdf.Encrypt(block[:], block[:])
+		return block[index : index+size]
+	}
+
+	for _, v := range rfcVectors {
+		if testing.Short() && v.count > 1<<20 {
+			continue
+		}
+		msg := bytes.Repeat([]byte(v.pattern), v.count)
+		for _, c := range []struct {
+			size int
+			tag  string
+		}{{4, v.tag4}, {8, v.tag8}, {12, v.tag12}} {
+			u, err := NewUHASH(key, c.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.Write(msg)
+			h := u.Sum(nil)
+			// Sum leaves the state alone
+			if again := u.Sum(nil); !bytes.Equal(h, again) {
+				t.Fatalf("UHASH-%d: second Sum %X, first %X", c.size*8, again, h)
+			}
+			for i, p := range pad(c.size) {
+				h[i] ^= p
+			}
+			if target, _ := hex.DecodeString(c.tag); !bytes.Equal(h, target) {
+				t.Errorf("UHASH-%d of %q*%d xor pad: %X, expected %s", c.size*8, v.pattern, v.count, h, c.tag)
+			}
+		}
+	}
+
+	// UHASH-128 has no RFC vector, it matches UMAC-128 the same way
+	msg := bytes.Repeat([]byte("abc"), 500)
+	u, _ := NewUHASH(key, 16)
+	u.Write(msg[:700])
+	u.Write(msg[700:])
+	h := u.Sum(nil)
+	for i, p := range pad(16) {
+		h[i] ^= p
+	}
+	k, _ := NewKey16(key)
+	if want := k.MAC(nil, nonce, msg); !bytes.Equal(h, want) {
+		t.Errorf("UHASH-128 xor pad: %X, expected %X", h, want)
+	}
+	u.Reset()
+	if h, _ := NewUHASH(key, 16); !bytes.Equal(u.Sum(nil), h.Sum(nil)) {
+		t.Error("Reset did not clear the state")
+	}
+	if _, err := NewUHASH(key, 5); err != TagSizeError(5) {
+		t.Errorf("NewUHASH with size 5: err = %v", err)
+	}
+}
+
+func TestNH(t *testing.T) {
+	for _, impl := range nhImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			defer impl.use()()
+			testNH(t)
+		})
+	}
+}
+
+func testNH(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	k, _ := NewKey(key)
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, MaxNHKeyLen+1)
+	rnd.Read(data)
+
+	// with the UMAC key length, NH is the L1 hash of a single block
+	l1 := []func(msg []byte, res []uint64){
+		func(msg []byte, res []uint64) { var c nhCtx4; c.init(&k.nh); c.hashShort(msg, res) },
+		func(msg []byte, res []uint64) { var c nhCtx8; c.init(&k.nh); c.hashShort(msg, res) },
+		func(msg []byte, res []uint64) { var c nhCtx12; c.init(&k.nh); c.hashShort(msg, res) },
+		func(msg []byte, res []uint64) { var c nhCtx16; c.init(&k.nh); c.hashShort(msg, res) },
+	}
+	for s := 1; s <= STREAMS16; s++ {
+		h, err := NewNH(key, s, L1_KEY_LEN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		long, _ := NewNH(key, s, MaxNHKeyLen)
+		for _, n := range []int{0, 1, 31, 32, 33, 64, 100, 1000, 1023, 1024} {
+			want := make([]uint64, s)
+			l1[s-1](data[:n], want)
+			got, err := h.Hash(nil, data[:n])
+			if err != nil || !equalUint64s(got, want) {
+				t.Fatalf("%d streams, %d bytes: Hash = %x, %v, expected %x", s, n, got, err, want)
+			}
+			// a longer key starts with the same words
+			if got, _ := long.Hash(nil, data[:n]); !equalUint64s(got, want) {
+				t.Errorf("%d streams, key length %d, %d bytes: Hash = %x, expected %x", s, MaxNHKeyLen, n, got, want)
+			}
+		}
+		if _, err := h.Hash(nil, data[:L1_KEY_LEN+1]); err != ErrNHTooLong {
+			t.Errorf("Hash of an over-long message: err = %v", err)
+		}
+	}
+
+	// streaming in random pieces gives the one-shot result
+	for _, keyLen := range []int{32, 64, 96, 1024, MaxNHKeyLen} {
+		for s := 1; s <= STREAMS16; s++ {
+			h, _ := NewNH(key, s, keyLen)
+			for i := 0; i < 20; i++ {
+				n := rnd.Intn(keyLen + 1)
+				h.Reset()
+				for off := 0; off < n; {
+					end := off + rnd.Intn(n-off+1)
+					h.Write(data[off:end])
+					off = end
+				}
+				want, _ := h.Hash(nil, data[:n])
+				if got := h.Sum64(nil); !equalUint64s(got, want) {
+					t.Fatalf("key length %d, %d streams, %d bytes: Sum64 = %x, expected %x", keyLen, s, n, got, want)
+				}
+			}
+			before := h.Sum64(nil)
+			if _, err := h.Write(data[:keyLen+1]); err != ErrNHTooLong {
+				t.Errorf("Write past the key length: err = %v", err)
+			}
+			if got := h.Sum64(nil); !equalUint64s(got, before) {
+				t.Error("a failed Write changed the state")
+			}
+		}
+	}
+
+	// padding does not hide the length
+	h, _ := NewNH(key, 2, 64)
+	a, _ := h.Hash(nil, []byte("abc"))
+	b, _ := h.Hash(nil, []byte("abc\x00"))
+	if equalUint64s(a, b) {
+		t.Error("zero padding collides with the padded message")
+	}
+}
+
+func equalUint64s(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewNHErrors(t *testing.T) {
+	key := make([]byte, 16)
+	for _, c := range [][2]int{{0, 64}, {5, 64}, {1, 0}, {1, 48}, {1, MaxNHKeyLen + 32}} {
+		if _, err := NewNH(key, c[0], c[1]); err != ErrNHParams {
+			t.Errorf("NewNH(%d streams, key length %d): err = %v", c[0], c[1], err)
+		}
+	}
+	if _, err := NewNH(key[:3], 1, 64); err != KeySizeError(3) {
+		t.Errorf("NewNH with a 3-byte key: err = %v", err)
+	}
+}
+
+// TestKeyNewNH checks that NH of a key schedule uses its cipher, like the MACs.
+func TestKeyNewNH(t *testing.T) {
+	k, err := NewKeyWithCipher([]byte("abcdefghijklmnop"), func(key []byte) (cipher.Block, error) {
+		return &fakeBlock{key: append([]byte(nil), key...), size: 16}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := k.NewNH(STREAMS8, L1_KEY_LEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := bytes.Repeat([]byte("abc"), 100)
+	var c nhCtx8
+	c.init(&k.nh)
+	want := make([]uint64, STREAMS8)
+	c.hashShort(msg, want)
+	if got, _ := h.Hash(nil, msg); !equalUint64s(got, want) {
+		t.Errorf("Hash = %x, expected %x", got, want)
+	}
+	if _, err := k.NewNH(0, L1_KEY_LEN); err != ErrNHParams {
+		t.Errorf("NewNH with no streams: err = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package umac
+
+import "crypto/subtle"
+
+// MinTagSize is the shortest truncated tag SetMinTagSize allows, the UMAC-32 tag size.
+// A forger guesses a tag of n bytes with probability 2^-8n per try,
+// shorter tags would make that practical.
+const MinTagSize = 4
+
+// verifyTag compares tag with the leading bytes of expected in constant time,
+// tags shorter than minTag or longer than expected are rejected.
+func verifyTag(expected, tag []byte, minTag int) bool {
+	if len(tag) < minTag || len(tag) > len(expected) {
+		return false
+	}
+	return subtle.ConstantTimeCompare(expected[:len(tag)], tag) == 1
+}
+
+// SetMinTagSize sets the shortest truncated tag accepted by Verify and VerifyTag,
+// at least MinTagSize, by default only full 4-byte tags are accepted.
+func (u *UMAC4) SetMinTagSize(n int) error {
+	if n < MinTagSize || n > 4 {
+		return TagSizeError(n)
+	}
+	u.minTag = n
+	return nil
+}
+
+// VerifyTag reports whether tag is the (possibly truncated) tag of the data written so far.
+// Like Sum, it resets the state.
+func (u *UMAC4) VerifyTag(nonce, tag []byte) bool {
+	if len(nonce) != NonceSize {
+		return false
+	}
+	var buf [8]byte
+	copy(buf[:], nonce)
+	minTag := u.minTag
+	if minTag == 0 {
+		minTag = 4
+	}
+	return verifyTag(u.Sum(buf[:NonceSize]), tag, minTag)
+}
+
+// Verify reports whether tag is the (possibly truncated) tag of msg, the state is reset before and after.
+func (u *UMAC4) Verify(nonce, msg, tag []byte) bool {
+	u.Reset()
+	u.Write(msg)
+	return u.VerifyTag(nonce, tag)
+}
+
+// SetMinTagSize sets the shortest truncated tag accepted by Verify and VerifyTag,
+// at least MinTagSize, by default only full 8-byte tags are accepted.
+func (u *UMAC8) SetMinTagSize(n int) error {
+	if n < MinTagSize || n > 8 {
+		return TagSizeError(n)
+	}
+	u.minTag = n
+	return nil
+}
+
+// VerifyTag reports whether tag is the (possibly truncated) tag of the data written so far.
+// Like Sum, it resets the state.
+func (u *UMAC8) VerifyTag(nonce, tag []byte) bool {
+	if len(nonce) != NonceSize {
+		return false
+	}
+	var buf [8]byte
+	copy(buf[:], nonce)
+	minTag := u.minTag
+	if minTag == 0 {
+		minTag = 8
+	}
+	return verifyTag(u.Sum(buf[:NonceSize]), tag, minTag)
+}
+
+// Verify reports whether tag is the (possibly truncated) tag of msg, the state is reset before and after.
+func (u *UMAC8) Verify(nonce, msg, tag []byte) bool {
+	u.Reset()
+	u.Write(msg)
+	return u.VerifyTag(nonce, tag)
+}
+
+// SetMinTagSize sets the shortest truncated tag accepted by Verify and VerifyTag,
+// at least MinTagSize, by default only full 12-byte tags are accepted.
+func (u *UMAC12) SetMinTagSize(n int) error {
+	if n < MinTagSize || n > 12 {
+		return TagSizeError(n)
+	}
+	u.minTag = n
+	return nil
+}
+
+// VerifyTag reports whether tag is the (possibly truncated) tag of the data written so far.
+// Like Sum, it resets the state.
+func (u *UMAC12) VerifyTag(nonce, tag []byte) bool {
+	if len(nonce) != NonceSize {
+		return false
+	}
+	var buf [12]byte
+	copy(buf[:], nonce)
+	minTag := u.minTag
+	if minTag == 0 {
+		minTag = 12
+	}
+	return verifyTag(u.Sum(buf[:NonceSize]), tag, minTag)
+}
+
+// Verify reports whether tag is the (possibly truncated) tag of msg, the state is reset before and after.
+func (u *UMAC12) Verify(nonce, msg, tag []byte) bool {
+	u.Reset()
+	u.Write(msg)
+	return u.VerifyTag(nonce, tag)
+}
+
+// SetMinTagSize sets the shortest truncated tag accepted by Verify and VerifyTag,
+// at least MinTagSize, by default only full 16-byte tags are accepted.
+func (u *UMAC16) SetMinTagSize(n int) error {
+	if n < MinTagSize || n > 16 {
+		return TagSizeError(n)
+	}
+	u.minTag = n
+	return nil
+}
+
+// VerifyTag reports whether tag is the (possibly truncated) tag of the data written so far.
+// Like Sum, it resets the state.
+func (u *UMAC16) VerifyTag(nonce, tag []byte) bool {
+	if len(nonce) != NonceSize {
+		return false
+	}
+	var buf [16]byte
+	copy(buf[:], nonce)
+	minTag := u.minTag
+	if minTag == 0 {
+		minTag = 16
+	}
+	return verifyTag(u.Sum(buf[:NonceSize]), tag, minTag)
+}
+
+// Verify reports whether tag is the (possibly truncated) tag of msg, the state is reset before and after.
+func (u *UMAC16) Verify(nonce, msg, tag []byte) bool {
+	u.Reset()
+	u.Write(msg)
+	return u.VerifyTag(nonce, tag)
+}
@@ -0,0 +1,81 @@
+package umac
+
+import (
+	"bytes"
+	"hash"
+	"testing"
+)
+
+type verifier interface {
+	hash.Hash
+	SetMinTagSize(int) error
+	VerifyTag(nonce, tag []byte) bool
+	Verify(nonce, msg, tag []byte) bool
+}
+
+func TestVerify(t *testing.T) {
+	key := []byte("abcdefghijklmnop")
+	nonce := []byte("bcdefghi")
+	msg := bytes.Repeat([]byte("abc"), 500)
+
+	u8, _ := NewUMAC8(key)
+	u16, _ := NewUMAC16(key)
+	for _, u := range []verifier{u8, u16} {
+		u.Write(msg)
+		tag := u.Sum(append([]byte(nil), nonce...))
+
+		if !u.Verify(nonce, msg, tag) {
+			t.Errorf("UMAC-%d: valid tag rejected", u.Size()*8)
+		}
+		u.Write(msg[:100])
+		u.Write(msg[100:])
+		if !u.VerifyTag(nonce, tag) {
+			t.Errorf("UMAC-%d: valid streaming tag rejected", u.Size()*8)
+		}
+
+		bad := append([]byte(nil), tag...)
+		bad[len(bad)-1] ^= 1
+		if u.Verify(nonce, msg, bad) {
+			t.Errorf("UMAC-%d: modified tag accepted", u.Size()*8)
+		}
+		if u.Verify([]byte("bcdefghj"), msg, tag) {
+			t.Errorf("UMAC-%d: tag accepted with another nonce", u.Size()*8)
+		}
+		if u.Verify(nonce, msg[1:], tag) {
+			t.Errorf("UMAC-%d: tag accepted for another message", u.Size()*8)
+		}
+		if u.Verify(nonce[:7], msg, tag) {
+			t.Errorf("UMAC-%d: tag accepted with short nonce", u.Size()*8)
+		}
+		if u.Verify(nonce, msg, append(tag, 0)) {
+			t.Errorf("UMAC-%d: overlong tag accepted", u.Size()*8)
+		}
+
+		if u.Verify(nonce, msg, tag[:4]) {
+			t.Errorf("UMAC-%d: truncated tag accepted by default", u.Size()*8)
+		}
+		if err := u.SetMinTagSize(4); err != nil {
+			t.Fatal(err)
+		}
+		if !u.Verify(nonce, msg, tag[:4]) || !u.Verify(nonce, msg, tag[:6]) {
+			t.Errorf("UMAC-%d: truncated tag rejected", u.Size()*8)
+		}
+		if u.Verify(nonce, msg, tag[:3]) {
+			t.Errorf("UMAC-%d: tag shorter than minimum accepted", u.Size()*8)
+		}
+		if u.Verify(nonce, msg, nil) {
+			t.Errorf("UMAC-%d: empty tag accepted", u.Size()*8)
+		}
+		for n := 0; n < MinTagSize; n++ {
+			if err := u.SetMinTagSize(n); err != TagSizeError(n) {
+				t.Errorf("UMAC-%d: SetMinTagSize accepted %d-byte tags: %v", u.Size()*8, n, err)
+			}
+		}
+		if u.Verify(nonce, msg, tag[:3]) {
+			t.Errorf("UMAC-%d: tag shorter than MinTagSize accepted after a rejected SetMinTagSize", u.Size()*8)
+		}
+		if err := u.SetMinTagSize(u.Size() + 1); err != TagSizeError(u.Size()+1) {
+			t.Errorf("UMAC-%d: SetMinTagSize accepted an oversize tag: %v", u.Size()*8, err)
+		}
+	}
+}